@@ -0,0 +1,241 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/AlfanDutaPamungkas/Govel/internal/store"
+)
+
+type DeleteAccountPayload struct {
+	Password     string `json:"password" validate:"required,max=72"`
+	Code         string `json:"code" validate:"omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" validate:"omitempty,max=11"`
+}
+
+type DeleteAccountResponse struct {
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+//	deleteAccountHandler godoc
+//
+//	@Summary		Delete account
+//	@Description	Schedule the current account for deletion and sign out everywhere. Signing in again before purge_at cancels the deletion. Invoices are kept anonymized for accounting
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			payload	body		DeleteAccountPayload	true	"Password, plus a code when two-factor authentication is enabled"
+//	@Success		202		{object}	DeleteAccountResponse	"Deletion scheduled"
+//	@Failure		400		{object}	swagger.EnvelopeError	"Invalid password or code"
+//	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/users/ [delete]
+func (app *application) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var payload DeleteAccountPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !user.Password.Verify(payload.Password) {
+		app.badRequestResponse(w, r, errors.New("password is incorrect"))
+		return
+	}
+
+	ctx := r.Context()
+
+	if user.TOTPEnabled {
+		if payload.Code == "" && payload.RecoveryCode == "" {
+			app.badRequestResponse(w, r, errors.New("a two-factor code is required"))
+			return
+		}
+
+		if err := app.verifySecondFactor(ctx, user, payload.Code, payload.RecoveryCode); err != nil {
+			switch {
+			case errors.Is(err, errInvalidSecondFactor):
+				app.badRequestResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	deletedAt, err := app.store.Users.SoftDelete(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Sessions.RevokeAll(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	resp := DeleteAccountResponse{
+		DeletedAt: deletedAt,
+		PurgeAt:   deletedAt.Add(app.config.deletion.gracePeriod),
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, resp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type UserDataExport struct {
+	ExportedAt time.Time                `json:"exported_at"`
+	Profile    *store.User              `json:"profile"`
+	Identities []*store.Identity        `json:"identities"`
+	Bookmarks  []*store.Bookmark        `json:"bookmarks"`
+	History    []*store.History         `json:"history"`
+	Unlocks    []*store.UserUnlock      `json:"unlocks"`
+	Invoices   []*store.Invoice         `json:"invoices"`
+	Coins      []*store.CoinTransaction `json:"coins"`
+}
+
+//	exportUserDataHandler godoc
+//
+//	@Summary		Export personal data
+//	@Description	Download everything stored about the current user as a JSON document, or as a ZIP archive with one JSON file per section when format=zip
+//	@Tags			users
+//	@Produce		json
+//	@Produce		application/zip
+//	@Security		BearerAuth
+//	@Param			format	query		string	false	"json (default) or zip"
+//	@Success		200		{object}	UserDataExport			"Personal data"
+//	@Failure		400		{object}	swagger.EnvelopeError	"Unknown format"
+//	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/users/export [get]
+func (app *application) exportUserDataHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	if format != "json" && format != "zip" {
+		app.badRequestResponse(w, r, errors.New("format must be json or zip"))
+		return
+	}
+
+	data, err := app.collectUserData(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("govel-export-%d-%s", user.ID, data.ExportedAt.Format("20060102"))
+	w.Header().Set("Cache-Control", "no-store")
+
+	if format == "json" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+
+		if err := app.jsonResponse(w, http.StatusOK, data); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		return
+	}
+
+	sections := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", data.Profile},
+		{"identities.json", data.Identities},
+		{"bookmarks.json", data.Bookmarks},
+		{"history.json", data.History},
+		{"unlocks.json", data.Unlocks},
+		{"invoices.json", data.Invoices},
+		{"coins.json", data.Coins},
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".zip"))
+	w.WriteHeader(http.StatusOK)
+
+	zw := zip.NewWriter(w)
+	for _, section := range sections {
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     section.name,
+			Method:   zip.Deflate,
+			Modified: data.ExportedAt,
+		})
+		if err != nil {
+			app.logger.Errorw("error writing data export", "user", user.ID, "error", err)
+			return
+		}
+
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(section.data); err != nil {
+			app.logger.Errorw("error writing data export", "user", user.ID, "error", err)
+			return
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		app.logger.Errorw("error writing data export", "user", user.ID, "error", err)
+	}
+}
+
+func (app *application) collectUserData(r *http.Request, user *store.User) (*UserDataExport, error) {
+	ctx := r.Context()
+
+	identities, err := app.store.Identities.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	bookmarks, err := app.store.Bookmarks.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := app.store.Histories.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	unlocks, err := app.store.UserUnlocks.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	invoices, err := app.store.Invoices.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	coins, err := app.store.Coins.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserDataExport{
+		ExportedAt: time.Now().UTC(),
+		Profile:    user,
+		Identities: identities,
+		Bookmarks:  bookmarks,
+		History:    history,
+		Unlocks:    unlocks,
+		Invoices:   invoices,
+		Coins:      coins,
+	}, nil
+}
@@ -3,30 +3,43 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"net/netip"
 	"time"
 
 	"github.com/AlfanDutaPamungkas/Govel/docs"
 	"github.com/AlfanDutaPamungkas/Govel/internal/auth"
+	"github.com/AlfanDutaPamungkas/Govel/internal/cache"
 	cld "github.com/AlfanDutaPamungkas/Govel/internal/cloudinary"
 	"github.com/AlfanDutaPamungkas/Govel/internal/mailer"
+	"github.com/AlfanDutaPamungkas/Govel/internal/payments"
+	"github.com/AlfanDutaPamungkas/Govel/internal/ratelimiter"
 	"github.com/AlfanDutaPamungkas/Govel/internal/store"
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
-	"github.com/xendit/xendit-go/v6"
 	"go.uber.org/zap"
 )
 
 type application struct {
-	config        config
-	logger        *zap.SugaredLogger
-	store         store.Storage
-	mailer        *mailer.SMTPMailer
-	authenticator auth.Authenticator
-	cld           *cloudinary.Cloudinary
-	xendit        *xendit.APIClient
+	config          config
+	logger          *zap.SugaredLogger
+	store           store.Storage
+	mailer          *mailer.SMTPMailer
+	authenticator   auth.Authenticator
+	cld             *cloudinary.Cloudinary
+	payments        payments.Provider
+	loginLimiter    *ratelimiter.Backoff
+	resendLimiter   *ratelimiter.Backoff
+	forgotLimiter   *ratelimiter.Backoff
+	forgotIPLimiter *ratelimiter.Backoff
+	oidcProviders   map[string]*auth.OIDCProvider
+	passwords       *auth.PasswordPolicy
+	suggestions     *cache.LRU[string, []*store.Suggestion]
+	proxies         []netip.Prefix
+
+	releaseNotices chan chapterNotice
 }
 
 type config struct {
@@ -35,21 +48,103 @@ type config struct {
 	env              string
 	db               dbConfig
 	mail             mailConfig
+	activation       activationConfig
+	deletion         deletionConfig
+	chapter          chapterConfig
+	search           searchConfig
 	frontendURL      string
 	auth             authConfig
 	ForgotPassExp    time.Duration
 	cloudinaryConfig *cld.CloudinaryConfig
-	xenditSecret     string
+	paymentProvider  string
+	xendit           xenditConfig
+	// trustedProxies lists the proxies, as IPs or CIDRs separated by commas,
+	// whose forwarded client address rate limits may rely on
+	trustedProxies string
+}
+
+type xenditConfig struct {
+	secretKey string
+	// callbackToken is the verification token from the Xendit dashboard
+	// that comes with every callback
+	callbackToken string
 }
 
 type authConfig struct {
-	token tokenConfig
+	token     tokenConfig
+	refresh   refreshConfig
+	lockout   lockoutConfig
+	forgot    forgotPasswordConfig
+	twoFactor twoFactorConfig
+	oidc      oidcConfig
+	password  passwordConfig
+}
+
+type passwordConfig struct {
+	minLength     int
+	requireUpper  bool
+	requireLower  bool
+	requireDigit  bool
+	requireSymbol bool
+	bannedList    string
+	breachedList  string
+	hash          passwordHashConfig
+}
+
+type passwordHashConfig struct {
+	algorithm         string
+	bcryptCost        int
+	argon2Memory      int
+	argon2Iterations  int
+	argon2Parallelism int
 }
 
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+	secret     string
+	keysDir    string
+	signingKid string
+	exp        time.Duration
+	iss        string
+}
+
+type refreshConfig struct {
+	exp time.Duration
+}
+
+// forgotPasswordConfig limits reset emails per address and, more loosely, per
+// IP, apart from the login limits.
+type forgotPasswordConfig struct {
+	maxRequests   int
+	ipMaxRequests int
+	backoff       time.Duration
+	maxBackoff    time.Duration
+}
+
+type lockoutConfig struct {
+	maxAttempts   int
+	duration      time.Duration
+	ipMaxAttempts int
+	ipBackoff     time.Duration
+	ipMaxBackoff  time.Duration
+}
+
+type twoFactorConfig struct {
+	issuer            string
+	challengeExp      time.Duration
+	requiredForAdmins bool
+}
+
+type oidcConfig struct {
+	stateExp  time.Duration
+	providers []oidcProviderConfig
+}
+
+type oidcProviderConfig struct {
+	name         string
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
 }
 
 type dbConfig struct {
@@ -63,6 +158,28 @@ type mailConfig struct {
 	smtp smtpConfig
 }
 
+type activationConfig struct {
+	resendMaxRequests int
+	resendBackoff     time.Duration
+	resendMaxBackoff  time.Duration
+	gracePeriod       time.Duration
+	sweepInterval     time.Duration
+}
+
+type deletionConfig struct {
+	gracePeriod time.Duration
+}
+
+type chapterConfig struct {
+	releaseInterval time.Duration
+	mailWorkers     int
+}
+
+type searchConfig struct {
+	suggestCacheSize int
+	suggestCacheTTL  time.Duration
+}
+
 type smtpConfig struct {
 	host     string
 	port     string
@@ -74,7 +191,9 @@ func (app *application) mount() http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
+	r.Use(peerAddrMiddleware)
 	r.Use(middleware.RealIP)
+	r.Use(app.auditMetaMiddleware)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(cors.Handler(cors.Options{
@@ -87,6 +206,8 @@ func (app *application) mount() http.Handler {
 	}))
 	r.Use(middleware.Timeout(60 * time.Second))
 
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
+
 	r.Route("/v1", func(r chi.Router) {
 		r.Get("/health", app.healthCheckHandler)
 
@@ -95,28 +216,68 @@ func (app *application) mount() http.Handler {
 
 		r.Route("/admin", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
-			r.With(app.AdminOnly()).Get("/check", app.checkAdmin)
+			r.With(app.RequirePermission(store.PermissionAdminAccess)).Get("/check", app.checkAdmin)
+			r.With(app.RequirePermission(store.PermissionUsersManage)).Patch("/users/{userID}/unlock", app.unlockUserHandler)
+			r.With(app.RequirePermission(store.PermissionUsersManage)).Get("/users/{userID}/sessions", app.getUserSessionsHandler)
+			r.With(app.RequirePermission(store.PermissionUsersManage)).Delete("/users/{userID}/sessions", app.deleteUserSessionsHandler)
+			r.With(app.RequirePermission(store.PermissionRolesManage)).Put("/users/{userID}/role", app.assignRoleHandler)
+			r.With(app.RequirePermission(store.PermissionRolesManage)).Get("/roles", app.getRolesHandler)
+			r.With(app.RequirePermission(store.PermissionAuditRead)).Get("/audit", app.getAuditEventsHandler)
+			r.With(app.RequirePermission(store.PermissionNovelsManageAny)).Get("/novels", app.getAllNovelAdminHandler)
+			r.With(app.RequirePermission(store.PermissionCoinsManage)).Post("/users/{userID}/coins", app.grantCoinsHandler)
+			r.With(app.RequirePermission(store.PermissionCoinsManage)).Get("/coins/reconcile", app.reconcileCoinsHandler)
+
+			r.Route("/plans", func(r chi.Router) {
+				r.Use(app.RequirePermission(store.PermissionPlansManage))
+				r.Get("/", app.getAllPlansHandler)
+				r.Post("/", app.createPlanHandler)
+
+				r.Route("/{planID}", func(r chi.Router) {
+					r.Use(app.plansContextMiddleware)
+					r.Put("/", app.updatePlanHandler)
+					r.Delete("/", app.deletePlanHandler)
+				})
+			})
 		})
 
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/logout", app.logoutHandler)
+			r.Post("/2fa", app.verifyTwoFactorHandler)
+			r.Get("/oidc/{provider}", app.oidcLoginHandler)
+			r.Get("/oidc/{provider}/callback", app.oidcCallbackHandler)
+			r.Post("/resend-activation", app.resendActivationHandler)
 			r.Post("/forgot-password", app.forgotPasswordHandler)
 			r.Patch("/reset-password/{token}", app.resetPasswordHandler)
 		})
 
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Put("/email/{token}", app.confirmEmailChangeHandler)
 
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
 
 				r.Get("/", app.getProfileHandler)
 				r.Patch("/", app.updateUserHandler)
+				r.Delete("/", app.deleteAccountHandler)
+				r.Get("/export", app.exportUserDataHandler)
 				r.Patch("/image", app.changeUserImageHandler)
 				r.Patch("/change-password", app.changePasswordHandler)
 				r.Get("/bookmark", app.getBookmarkHandler)
+				r.Get("/coins/history", app.getCoinHistoryHandler)
 				r.Delete("/bookmark/{bookmarkID}", app.deleteBookmarkHandler)
+				r.Get("/sessions", app.getSessionsHandler)
+				r.Delete("/sessions/{sessionID}", app.deleteSessionHandler)
+				r.Post("/2fa/setup", app.setupTwoFactorHandler)
+				r.Post("/2fa/enable", app.enableTwoFactorHandler)
+				r.Delete("/2fa", app.disableTwoFactorHandler)
+				r.Get("/identities", app.getIdentitiesHandler)
+				r.Get("/novels", app.getOwnNovelsHandler)
+				r.Post("/author", app.becomeAuthorHandler)
+				r.Delete("/identities/{provider}", app.deleteIdentityHandler)
 			})
 
 			r.Route("/{userID}", func(r chi.Router) {
@@ -126,13 +287,15 @@ func (app *application) mount() http.Handler {
 			})
 		})
 
+		r.Get("/search/suggest", app.suggestHandler)
+
 		r.Route("/genres", func(r chi.Router) {
 			r.Get("/", app.getAllGenreHandler)
 
 			r.Group(func(r chi.Router) {
 				r.Group(func(r chi.Router) {
 					r.Use(app.AuthTokenMiddleware)
-					r.With(app.AdminOnly()).Post("/", app.createGenreHandler)
+					r.With(app.RequirePermission(store.PermissionGenresWrite)).Post("/", app.createGenreHandler)
 				})
 
 				r.Route("/{genreID}", func(r chi.Router) {
@@ -142,8 +305,8 @@ func (app *application) mount() http.Handler {
 					r.Group(func(r chi.Router) {
 						r.Use(app.AuthTokenMiddleware)
 
-						r.With(app.AdminOnly()).Put("/", app.updateGenreHandler)
-						r.With(app.AdminOnly()).Delete("/", app.deleteGenreHandler)
+						r.With(app.RequirePermission(store.PermissionGenresWrite)).Put("/", app.updateGenreHandler)
+						r.With(app.RequirePermission(store.PermissionGenresWrite)).Delete("/", app.deleteGenreHandler)
 					})
 				})
 			})
@@ -155,29 +318,30 @@ func (app *application) mount() http.Handler {
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
 	
-				r.With(app.AdminOnly()).Post("/", app.createNovelHandler)
+				r.With(app.RequirePermission(store.PermissionNovelsWrite)).Post("/", app.createNovelHandler)
 	
 				r.Route("/{novelID}", func(r chi.Router) {
 					r.Use(app.novelsContextMiddleware)
 	
 					r.Get("/", app.getNovelHandler)
 	
-					r.With(app.AdminOnly()).Patch("/", app.updateNovelHandler)
-					r.With(app.AdminOnly()).Patch("/image", app.changeNovelImageHandler)
-					r.With(app.AdminOnly()).Delete("/", app.deleteNovelHandler)
+					r.With(app.RequirePermission(store.PermissionNovelsWrite), app.NovelOwnerOnly()).Patch("/", app.updateNovelHandler)
+					r.With(app.RequirePermission(store.PermissionNovelsWrite), app.NovelOwnerOnly()).Patch("/image", app.changeNovelImageHandler)
+					r.With(app.RequirePermission(store.PermissionNovelsWrite), app.NovelOwnerOnly()).Patch("/status", app.setNovelStatusHandler)
+					r.With(app.RequirePermission(store.PermissionNovelsDelete), app.NovelOwnerOnly()).Delete("/", app.deleteNovelHandler)
 
 					r.Post("/bookmark", app.createBookmarkHandler)
 	
 					r.Route("/chapters", func(r chi.Router) {
-						r.With(app.AdminOnly()).Post("/", app.createChapterHandler)
+						r.With(app.RequirePermission(store.PermissionChaptersPublish), app.NovelOwnerOnly()).Post("/", app.createChapterHandler)
 	
 						r.Route("/{slug}", func(r chi.Router) {
 							r.Use(app.chaptersContextMiddleware)
 	
 							r.With(app.CheckPremium()).Get("/", app.getDetailChapterHandler)
 	
-							r.With(app.AdminOnly()).Patch("/", app.updateChapterHandler)
-							r.With(app.AdminOnly()).Delete("/", app.deleteChapterHandler)
+							r.With(app.RequirePermission(store.PermissionChaptersPublish), app.NovelOwnerOnly()).Patch("/", app.updateChapterHandler)
+							r.With(app.RequirePermission(store.PermissionChaptersPublish), app.NovelOwnerOnly()).Delete("/", app.deleteChapterHandler)
 	
 							r.Post("/unlock", app.unlockChapterHandler)
 						})
@@ -187,10 +351,12 @@ func (app *application) mount() http.Handler {
 
 		})
 
+		r.Get("/plans", app.getPlansHandler)
+
 		r.Route("/invoices", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
 
-			r.With(app.AdminOnly()).Get("/all", app.getAllInvoicesHandler)
+			r.With(app.RequirePermission(store.PermissionInvoicesRead)).Get("/all", app.getAllInvoicesHandler)
 			r.Get("/", app.getInvoiceHandler)
 
 			r.Route("/{plan}", func(r chi.Router) {
@@ -198,6 +364,10 @@ func (app *application) mount() http.Handler {
 			})
 		})
 
+		if fake, ok := app.payments.(*payments.FakeProvider); ok {
+			r.Mount("/payments/fake", fake.Routes())
+		}
+
 		r.Route("/webhook", func(r chi.Router) {
 			r.Post("/", app.transactionHandler)
 		})
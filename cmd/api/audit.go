@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AlfanDutaPamungkas/Govel/internal/store"
+)
+
+const (
+	auditDefaultLimit = 50
+	auditMaxLimit     = 500
+)
+
+//	getAuditEventsHandler godoc
+//
+//	@Summary		List audit events
+//	@Description	Newest first. Requires audit:read
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			actor_id	query		int		false	"User who made the change"
+//	@Param			action		query		string	false	"Action, e.g. novel.delete"
+//	@Param			target_type	query		string	false	"novel, chapter, genre or user"
+//	@Param			target_id	query		string	false	"Target ID, or slug for chapters"
+//	@Param			from		query		string	false	"RFC 3339 time, inclusive"
+//	@Param			to			query		string	false	"RFC 3339 time, exclusive"
+//	@Param			limit		query		int		false	"Max events, default 50, at most 500"
+//	@Success		200			{array}		store.AuditEvent
+//	@Failure		400			{object}	swagger.EnvelopeError	"Invalid filter"
+//	@Failure		401			{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		403			{object}	swagger.EnvelopeError	"Forbidden"
+//	@Failure		500			{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/admin/audit [get]
+func (app *application) getAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := store.AuditFilter{
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+		Limit:      auditDefaultLimit,
+	}
+
+	if v := query.Get("actor_id"); v != "" {
+		actorID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid actor_id: %w", err))
+			return
+		}
+		filter.ActorID = &actorID
+	}
+
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid %s: %w", name, err))
+			return
+		}
+		*dst = &t
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > auditMaxLimit {
+			app.badRequestResponse(w, r, fmt.Errorf("limit must be between 1 and %d", auditMaxLimit))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	events, err := app.store.Audit.GetAll(r.Context(), filter)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, events); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -3,10 +3,13 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/AlfanDutaPamungkas/Govel/internal/auth"
 	"github.com/AlfanDutaPamungkas/Govel/internal/mailer"
 	"github.com/AlfanDutaPamungkas/Govel/internal/store"
 	"github.com/go-chi/chi/v5"
@@ -17,7 +20,7 @@ import (
 type RegisterUserPayload struct {
 	Username string `json:"username" validate:"required,max=100"`
 	Email    string `json:"email" validate:"required,email,max=255"`
-	Password string `json:"password" validate:"required,min=3,max=72"`
+	Password string `json:"password" validate:"required,max=72"`
 }
 
 type UserWithToken struct {
@@ -49,6 +52,11 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if err := app.passwords.Validate(payload.Password, payload.Username, payload.Email); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	user := &store.User{
 		Username: payload.Username,
 		Email:    payload.Email,
@@ -113,18 +121,28 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 type CreateUserTokenPayload struct {
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=3,max=72"`
+	Device   string `json:"device" validate:"max=255"`
+}
+
+type TokenResponse struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
 }
 
 //	createTokenHandler godoc
 //
 //	@Summary		Creates a token
-//	@Description	Creates a token for a user
+//	@Description	Creates a short-lived access token and a refresh token for a user
 //	@Tags			authentication
 //	@Accept			json
 //	@Produce		json
 //	@param			payload	body		CreateUserTokenPayload	true	"User credentials"
-//	@Success		201		{object}	swagger.EnvelopeString	"Token"
+//	@Success		201		{object}	TokenResponse				"Access and refresh token"
+//	@Success		202		{object}	TwoFactorChallengeResponse	"Two-factor code required, continue with /authentication/2fa"
 //	@Failure		400		{object}	swagger.EnvelopeError	"invalid request"
+//	@Failure		401		{object}	swagger.EnvelopeError	"invalid credentials"
+//	@Failure		429		{object}	swagger.EnvelopeError	"too many failed attempts"
 //	@Failure		500		{object}	swagger.EnvelopeError	"internal server error"
 //	@Router			/authentication/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -139,10 +157,17 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	ip := app.limiterIP(r)
+	if retryAfter, blocked := app.loginLimiter.Blocked(ip); blocked {
+		app.rateLimitExceededResponse(w, r, retryAfter)
+		return
+	}
+
 	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
+			app.loginLimiter.Fail(ip)
 			app.unauthorizedResponse(w, r, fmt.Errorf("invalid credentials"))
 		default:
 			app.internalServerError(w, r, err)
@@ -150,31 +175,320 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		app.rateLimitExceededResponse(w, r, time.Until(*user.LockedUntil))
+		return
+	}
+
 	if !user.Password.Verify(payload.Password) {
+		app.loginLimiter.Fail(ip)
+		app.recordFailedLogin(r, user)
 		app.unauthorizedResponse(w, r, fmt.Errorf("invalid credentials"))
 		return
 	}
 
+	if user.Password.NeedsRehash() {
+		app.rehashPassword(r, user, payload.Password)
+	}
+
+	if user.FailedLoginAttempts > 0 {
+		if err := app.store.Users.ResetFailedLogins(r.Context(), user.ID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := app.createTwoFactorChallenge(r.Context(), user, payload.Device)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if err := app.jsonResponse(w, http.StatusAccepted, challenge); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	tokens, err := app.issueTokens(r, user, payload.Device)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+//	refreshTokenHandler godoc
+//
+//	@Summary		Refreshes a token
+//	@Description	Exchanges a refresh token for a new access token and a rotated refresh token
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@param			payload	body		RefreshTokenPayload		true	"Refresh token"
+//	@Success		201		{object}	TokenResponse			"Access and refresh token"
+//	@Failure		400		{object}	swagger.EnvelopeError	"invalid request"
+//	@Failure		401		{object}	swagger.EnvelopeError	"invalid or reused refresh token"
+//	@Failure		500		{object}	swagger.EnvelopeError	"internal server error"
+//	@Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	plainToken := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	refreshToken, err := app.store.RefreshTokens.Rotate(ctx, payload.RefreshToken, hashToken, app.config.auth.refresh.exp)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedResponse(w, r, errors.New("invalid refresh token"))
+		case store.ErrRefreshTokenReused:
+			app.logger.Warnw("refresh token reuse detected", "ip", r.RemoteAddr)
+			app.unauthorizedResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, refreshToken.UserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedResponse(w, r, errors.New("invalid refresh token"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	token, expiresAt, err := app.generateAccessToken(user, refreshToken.SessionID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens := TokenResponse{
+		Token:        token,
+		RefreshToken: plainToken,
+		ExpiresAt:    expiresAt,
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+//	logoutHandler godoc
+//
+//	@Summary		Logs out a device
+//	@Description	Revokes the refresh token and every token rotated from the same login
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@param			payload	body	RefreshTokenPayload	true	"Refresh token"
+//	@Success		204		{}		"Refresh token revoked"
+//	@Failure		400		{object}	swagger.EnvelopeError	"invalid request"
+//	@Failure		500		{object}	swagger.EnvelopeError	"internal server error"
+//	@Router			/authentication/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	refreshToken, err := app.store.RefreshTokens.GetByToken(r.Context(), payload.RefreshToken)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.RefreshTokens.RevokeFamily(r.Context(), refreshToken.UserID, refreshToken.FamilyID, false); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) generateAccessToken(user *store.User, sessionID int64) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(app.config.auth.token.exp)
+
 	claims := jwt.MapClaims{
 		"sub":           user.ID,
-		"exp":           time.Now().Add(app.config.auth.token.exp).Unix(),
-		"iat":           time.Now().Unix(),
-		"nbf":           time.Now().Unix(),
+		"exp":           expiresAt.Unix(),
+		"iat":           now.Unix(),
+		"nbf":           now.Unix(),
 		"iss":           app.config.auth.token.iss,
 		"aud":           app.config.auth.token.iss,
 		"token_version": user.TokenVersion,
+		"sid":           sessionID,
 	}
 
 	token, err := app.authenticator.GenerateToken(claims)
 	if err != nil {
-		app.internalServerError(w, r, err)
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// issueTokens opens a session for a fresh login and starts its refresh token
+// family.
+func (app *application) issueTokens(r *http.Request, user *store.User, device string) (*TokenResponse, error) {
+	if device == "" {
+		device = r.UserAgent()
+	}
+
+	// signing in during the grace period cancels a pending deletion
+	if user.DeletedAt != nil {
+		if err := app.store.Users.Restore(r.Context(), user.ID); err != nil {
+			return nil, err
+		}
+		user.DeletedAt = nil
+	}
+
+	session := &store.Session{
+		UserID:     user.ID,
+		DeviceName: device,
+		IP:         clientIP(r),
+		UserAgent:  r.UserAgent(),
+	}
+
+	plainToken := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	refreshToken := &store.RefreshToken{
+		UserID:       user.ID,
+		FamilyID:     uuid.New().String(),
+		TokenVersion: user.TokenVersion,
+		Expiry:       time.Now().Add(app.config.auth.refresh.exp),
+	}
+
+	if err := app.store.Sessions.Create(r.Context(), session, refreshToken, hashToken); err != nil {
+		return nil, err
+	}
+
+	token, expiresAt, err := app.generateAccessToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		Token:        token,
+		RefreshToken: plainToken,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+type ResendActivationPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+//	resendActivationHandler godoc
+//
+//	@Summary		Resend activation email
+//	@Description	Sends a fresh activation link to an account that has not been activated yet. The response is the same whether or not such an account exists
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@param			payload	body	ResendActivationPayload	true	"Email of the pending account"
+//	@Success		202		{}			"Activation email sent if the account is pending"
+//	@Failure		400		{object}	swagger.EnvelopeError	"invalid request"
+//	@Failure		429		{object}	swagger.EnvelopeError	"too many requests"
+//	@Failure		500		{object}	swagger.EnvelopeError	"internal server error"
+//	@Router			/authentication/resend-activation [post]
+func (app *application) resendActivationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResendActivationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// limit per address as well as per IP so one inbox cannot be flooded
+	// from many IPs
+	keys := []string{"ip:" + app.limiterIP(r), "email:" + strings.ToLower(payload.Email)}
+	for _, key := range keys {
+		if retryAfter, blocked := app.resendLimiter.Blocked(key); blocked {
+			app.rateLimitExceededResponse(w, r, retryAfter)
+			return
+		}
+	}
+
+	for _, key := range keys {
+		app.resendLimiter.Fail(key)
+	}
+
+	plainToken := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	user, err := app.store.Users.Reinvite(r.Context(), payload.Email, hashToken, app.config.mail.exp)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusCreated, token); err != nil {
+	vars := struct {
+		Username      string
+		ActivationURL string
+	}{
+		Username:      user.Username,
+		ActivationURL: fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken),
+	}
+
+	if err := app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars); err != nil {
+		app.logger.Errorw("error resending activation email", "user_id", user.ID, "error", err)
 		app.internalServerError(w, r, err)
 		return
 	}
+
+	w.WriteHeader(http.StatusAccepted)
 }
 
 type ForgotPasswordPayload struct {
@@ -192,6 +506,7 @@ type ForgotPasswordPayload struct {
 //	@Success		201		{object}	swagger.EnvelopeString	"Plain reset token"
 //	@Failure		400		{object}	swagger.EnvelopeError	"invalid request"
 //	@Failure		404		{object}	swagger.EnvelopeError	"user npt found"
+//	@Failure		429		{object}	swagger.EnvelopeError	"too many requests"
 //	@Failure		500		{object}	swagger.EnvelopeError	"internal server error"
 //	@Router			/authentication/forgot-password [post]
 func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
@@ -205,7 +520,25 @@ func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Req
 		app.badRequestResponse(w, r, err)
 		return
 	}
-	
+
+	// every request sends an email, so each one counts against the address,
+	// and against the IP so one client can't mail many addresses
+	ip := app.limiterIP(r)
+	email := strings.ToLower(strings.TrimSpace(payload.Email))
+
+	if retryAfter, blocked := app.forgotIPLimiter.Blocked(ip); blocked {
+		app.rateLimitExceededResponse(w, r, retryAfter)
+		return
+	}
+
+	if retryAfter, blocked := app.forgotLimiter.Blocked(email); blocked {
+		app.rateLimitExceededResponse(w, r, retryAfter)
+		return
+	}
+
+	app.forgotIPLimiter.Fail(ip)
+	app.forgotLimiter.Fail(email)
+
 	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
 	if err != nil {
 		switch err {
@@ -256,13 +589,13 @@ func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Req
 }
 
 type ResetPasswordPayload struct {
-	Password string `json:"password" validate:"required,min=3,max=72"`
+	Password string `json:"password" validate:"required,max=72"`
 }
 
 //	resetPasswordHandler godoc
 //
 //	@Summary		Reset user password
-//	@Description	Reset password using token from forgot-password email
+//	@Description	Reset password using token from forgot-password email and sign out every session
 //	@Tags			authentication
 //	@Accept			json
 //	@Produce		json
@@ -286,7 +619,23 @@ func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err := app.store.Users.ResetPassword(r.Context(), token, payload.Password)
+	user, err := app.store.Users.GetByResetToken(r.Context(), token)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.passwords.Validate(payload.Password, user.Username, user.Email); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.store.Users.ResetPassword(r.Context(), token, payload.Password)
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:	
@@ -303,6 +652,74 @@ func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Requ
 	}
 }
 
+// rehashPassword upgrades an outdated hash while the plain password is at
+// hand. A failure only postpones the upgrade to the next login.
+func (app *application) rehashPassword(r *http.Request, user *store.User, password string) {
+	if err := user.Password.Set(password); err != nil {
+		app.logger.Errorw("error rehashing password", "user_id", user.ID, "error", err)
+		return
+	}
+
+	if err := app.store.Users.UpdatePassword(r.Context(), user); err != nil {
+		app.logger.Errorw("error rehashing password", "user_id", user.ID, "error", err)
+	}
+}
+
+// recordFailedLogin counts a wrong password against the account and emails
+// the owner the moment the account gets locked.
+func (app *application) recordFailedLogin(r *http.Request, user *store.User) {
+	lockout := app.config.auth.lockout
+
+	if err := app.store.Users.RecordFailedLogin(r.Context(), user, lockout.maxAttempts, lockout.duration); err != nil {
+		app.logger.Errorw("error recording failed login", "user_id", user.ID, "error", err)
+		return
+	}
+
+	if user.FailedLoginAttempts != lockout.maxAttempts || user.LockedUntil == nil {
+		return
+	}
+
+	vars := struct {
+		Username          string
+		LockedUntil       string
+		ForgotPasswordURL string
+	}{
+		Username:          user.Username,
+		LockedUntil:       user.LockedUntil.Format(time.RFC1123),
+		ForgotPasswordURL: fmt.Sprintf("%s/forgot-password", app.config.frontendURL),
+	}
+
+	go func() {
+		if err := app.mailer.Send(mailer.AccountLockedTemplate, user.Username, user.Email, vars); err != nil {
+			app.logger.Errorw("error sending account locked email", "user_id", user.ID, "error", err)
+		}
+	}()
+}
+
+//	jwksHandler godoc
+//
+//	@Summary		JSON Web Key Set
+//	@Description	Public keys that verify Govel access tokens
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		200	{object}	auth.JWKS
+//	@Failure		404	{object}	swagger.EnvelopeError	"tokens are not signed with asymmetric keys"
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	publisher, ok := app.authenticator.(auth.KeyPublisher)
+	if !ok {
+		app.notFoundResponse(w, r, errors.New("authenticator does not publish keys"))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	if err := writeJSON(w, http.StatusOK, publisher.JWKS()); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
 func (app *application) checkAdmin(w http.ResponseWriter, r *http.Request){
 	if err := app.jsonResponse(w, http.StatusOK, "ok"); err != nil {
 		app.internalServerError(w, r, err)
@@ -55,20 +55,36 @@ func (app *application) createBookmarkHandler(w http.ResponseWriter, r *http.Req
 //	@Accept			json
 //	@Produce		json
 //	@Security		BearerAuth
-//	@Success		200	{array}		store.Bookmark			"Get Bookmarks successfully"
-//	@Failure		401	{object}	swagger.EnvelopeError	"Unauthorize"
-//	@Failure		500	{object}	swagger.EnvelopeError	"Internal server error"
+//	@Param			sort_by	query		string							false	"bookmarked_at (default) or any novel sort key"
+//	@Param			cursor	query		string							false	"next_cursor from the previous page"
+//	@Param			limit	query		int								false	"Page size, default 20, at most 100"
+//	@Param			include_total	query	bool							false	"Count every match in total"
+//	@Success		200		{object}	store.Page[store.Bookmark]		"Get Bookmarks successfully"
+//	@Failure		400		{object}	swagger.EnvelopeError			"Invalid filter or cursor"
+//	@Failure		401		{object}	swagger.EnvelopeError			"Unauthorize"
+//	@Failure		500		{object}	swagger.EnvelopeError			"Internal server error"
 //	@Router			/users/bookmark [get]
 func (app *application) getBookmarkHandler(w http.ResponseWriter, r *http.Request){
 	user := getUserFromCtx(r)
 
-	bookmarks, err := app.store.Bookmarks.GetByUserID(r.Context(), user.ID)
+	filter, err := readNovelFilter(r)
 	if err != nil {
-		app.internalServerError(w, r, err)
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	page, err := app.store.Bookmarks.List(r.Context(), user.ID, filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidOption), errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, bookmarks); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
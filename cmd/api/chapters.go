@@ -17,17 +17,19 @@ type chapterKey string
 const chapterCtx chapterKey = "chapter"
 
 type CreateChapterPayload struct {
-	Title         string  `json:"title" validate:"required"`
-	Content       string  `json:"content" validate:"required"`
-	ChapterNumber float64 `json:"chapter_number" validate:"required"`
-	IsLocked      *bool   `json:"is_locked"`
-	Price         *int    `json:"price"`
+	Title           string     `json:"title" validate:"required,max=255,printable"`
+	Content         string     `json:"content" validate:"required"`
+	ChapterNumber   float64    `json:"chapter_number" validate:"required"`
+	IsLocked        *bool      `json:"is_locked"`
+	Price           *int       `json:"price" validate:"omitempty,min=0"`
+	EarlyAccessDays *int       `json:"early_access_days" validate:"omitempty,min=1"`
+	PublishAt       *time.Time `json:"publish_at"`
 }
 
 //	createChapterHandler godoc
 //
 //	@Summary		Create a new chapter
-//	@Description	Create a new chapter with slug, title, author, content, chapter number, price and status is locked. Admin only
+//	@Description	Create a new chapter with slug, title, author, content, chapter number, price and status is locked. Requires chapters:publish and owning the novel. A future publish_at schedules the chapter, which stays hidden from readers until then. early_access_days locks the chapter for that many days after publish_at, after which it is free
 //	@Tags			novels
 //	@Accept			json
 //	@Produce		json
@@ -65,6 +67,17 @@ func (app *application) createChapterHandler(w http.ResponseWriter, r *http.Requ
 		price = *payload.Price
 	}
 
+	publishAt := time.Now()
+	if payload.PublishAt != nil {
+		publishAt = *payload.PublishAt
+	}
+
+	var freeAt *time.Time
+	if payload.EarlyAccessDays != nil {
+		isLocked = true
+		freeAt = earlyAccessEnd(publishAt, *payload.EarlyAccessDays)
+	}
+
 	chapterSlug := helper.GenerateChapterSlug(novel.Title, payload.ChapterNumber)
 
 	chapter := &store.Chapter{
@@ -75,6 +88,8 @@ func (app *application) createChapterHandler(w http.ResponseWriter, r *http.Requ
 		ChapterNumber: payload.ChapterNumber,
 		IsLocked:      isLocked,
 		Price:         price,
+		FreeAt:        freeAt,
+		PublishAt:     publishAt,
 	}
 
 	if err := app.store.Chapters.Create(r.Context(), chapter); err != nil {
@@ -87,16 +102,21 @@ func (app *application) createChapterHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	novel.UpdatedAt = time.Now()
+	// scheduled chapters bump the novel and notify readers once released
+	if chapter.IsReleased() {
+		novel.UpdatedAt = time.Now()
 
-	if err := app.store.Novels.Update(r.Context(), novel); err != nil {
-		switch {
-		case errors.Is(err, store.ErrNotFound):
-			app.notFoundResponse(w, r, err)
-		default:
-			app.internalServerError(w, r, err)
+		if err := app.store.Novels.Update(r.Context(), novel); err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
 		}
-		return
+
+		go app.queueChapterReleased(context.Background(), novel, chapter)
 	}
 
 	if err := app.jsonResponse(w, http.StatusCreated, chapter); err != nil {
@@ -107,17 +127,19 @@ func (app *application) createChapterHandler(w http.ResponseWriter, r *http.Requ
 
 
 type UpdateChapterPayload struct {
-	Title         string   `json:"title"`
-	Content       string   `json:"content"`
-	ChapterNumber *float64 `json:"chapter_number"`
-	IsLocked      *bool    `json:"is_locked"`
-	Price         *int     `json:"price"`
+	Title           string     `json:"title" validate:"omitempty,max=255,printable"`
+	Content         string     `json:"content"`
+	ChapterNumber   *float64   `json:"chapter_number"`
+	IsLocked        *bool      `json:"is_locked"`
+	Price           *int       `json:"price" validate:"omitempty,min=0"`
+	EarlyAccessDays *int       `json:"early_access_days" validate:"omitempty,min=0"`
+	PublishAt       *time.Time `json:"publish_at"`
 }
 
 //	updateChapterHandler godoc
 //
 //	@Summary		Update chapter
-//	@Description	Update an existing chapter's title, content, chapter number, status is_locked or price. Admin only.
+//	@Description	Update an existing chapter's title, content, chapter number, status is_locked, price, early_access_days or, until it is released, publish_at. early_access_days of 0 or is_locked on its own end early access. Requires chapters:publish and owning the novel.
 //	@Tags			novels
 //	@Accept			json
 //	@Produce		json
@@ -142,11 +164,16 @@ func (app *application) updateChapterHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if payload.Title == "" && payload.Content == "" && payload.ChapterNumber == nil && payload.IsLocked == nil && payload.Price == nil {
+	if payload.Title == "" && payload.Content == "" && payload.ChapterNumber == nil && payload.IsLocked == nil && payload.Price == nil && payload.EarlyAccessDays == nil && payload.PublishAt == nil {
 		app.badRequestResponse(w, r, errors.New("please provide at least one field"))
 		return
 	}
 
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	if payload.Title != "" {
 		chapter.Title = payload.Title
 	}
@@ -159,14 +186,39 @@ func (app *application) updateChapterHandler(w http.ResponseWriter, r *http.Requ
 		chapter.ChapterNumber = *payload.ChapterNumber
 	}
 
+	// a lock set on its own is permanent
 	if payload.IsLocked != nil {
 		chapter.IsLocked = *payload.IsLocked
+		chapter.FreeAt = nil
 	}
 
 	if payload.Price != nil {
 		chapter.Price = *payload.Price
 	}
 
+	if payload.PublishAt != nil {
+		if chapter.IsReleased() {
+			app.badRequestResponse(w, r, errors.New("chapter is already published"))
+			return
+		}
+
+		// early access keeps its length when the chapter is rescheduled
+		if chapter.FreeAt != nil {
+			freeAt := chapter.FreeAt.Add(payload.PublishAt.Sub(chapter.PublishAt))
+			chapter.FreeAt = &freeAt
+		}
+
+		chapter.PublishAt = *payload.PublishAt
+	}
+
+	if payload.EarlyAccessDays != nil {
+		chapter.FreeAt = nil
+		if *payload.EarlyAccessDays > 0 {
+			chapter.IsLocked = true
+			chapter.FreeAt = earlyAccessEnd(chapter.PublishAt, *payload.EarlyAccessDays)
+		}
+	}
+
 	chapter.UpdatedAt = time.Now()
 
 	if err := app.store.Chapters.Update(r.Context(), chapter); err != nil {
@@ -278,10 +330,8 @@ func (app *application) unlockChapterHandler(w http.ResponseWriter, r *http.Requ
 	user := getUserFromCtx(r)
 	chapter := getChapterFromCtx(r)
 
-	app.logger.Info(user.Coin)
-
-	if user.Coin < int64(chapter.Price) {
-		app.paymentRequiredResponse(w, r, errors.New("insufficient coin"))
+	if !chapter.IsLocked {
+		app.badRequestResponse(w, r, errors.New("chapter is free"))
 		return
 	}
 
@@ -294,6 +344,10 @@ func (app *application) unlockChapterHandler(w http.ResponseWriter, r *http.Requ
 		switch {
 		case errors.Is(err, store.ErrAlreadyUnlocked):
 			app.badRequestResponse(w, r, err)
+		case errors.Is(err, store.ErrInsufficientCoin):
+			app.paymentRequiredResponse(w, r, err)
+		case errors.Is(err, store.ErrInvalidPrice):
+			app.badRequestResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
@@ -306,12 +360,24 @@ func (app *application) unlockChapterHandler(w http.ResponseWriter, r *http.Requ
 	}
 }
 
+// earlyAccessEnd is when a chapter published at publishAt becomes free after
+// days of early access.
+func earlyAccessEnd(publishAt time.Time, days int) *time.Time {
+	freeAt := publishAt.AddDate(0, 0, days)
+	return &freeAt
+}
+
 func (app *application) chaptersContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
+		user := getUserFromCtx(r)
+		novel := getNovelFromCtx(r)
 		slug := chi.URLParam(r, "slug")
 
-		chapter, err := app.store.Chapters.GetBySlug(ctx, slug)
+		// only those who manage the novel see its unreleased chapters
+		includeUnreleased := novel != nil && user.CanManageNovel(novel)
+
+		chapter, err := app.store.Chapters.GetBySlug(ctx, slug, includeUnreleased)
 		if err != nil {
 			switch {
 			case errors.Is(err, store.ErrNotFound):
@@ -322,6 +388,13 @@ func (app *application) chaptersContextMiddleware(next http.Handler) http.Handle
 			return
 		}
 
+		// the slug must belong to the novel in the path, otherwise ownership
+		// of one novel would grant access to chapters of another
+		if novel != nil && chapter.NovelID != novel.ID {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, chapterCtx, chapter)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/AlfanDutaPamungkas/Govel/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+//	getCoinHistoryHandler godoc
+//
+//	@Summary		Coin history
+//	@Description	Every change of the signed in user's coin balance, newest first: top-ups, chapter purchases, refunds, grants and bonuses
+//	@Tags			users
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			cursor	query		string	false	"next_cursor of the previous page"
+//	@Param			limit	query		int		false	"Page size, 1 to 100"
+//	@Param			include_total	query	bool	false	"Count every transaction in total"
+//	@Success		200		{object}	store.Page[store.CoinTransaction]
+//	@Failure		400		{object}	swagger.EnvelopeError	"Invalid cursor or limit"
+//	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/users/coins/history [get]
+func (app *application) getCoinHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	query := r.URL.Query()
+
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > store.MaxPageLimit {
+			app.badRequestResponse(w, r, fmt.Errorf("limit must be between 1 and %d", store.MaxPageLimit))
+			return
+		}
+	}
+
+	page, err := app.store.Coins.History(r.Context(), user.ID, query.Get("cursor"), limit, query.Get("include_total") == "true")
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type GrantCoinsPayload struct {
+	Kind      string `json:"kind" validate:"required,oneof=admin_grant bonus refund"`
+	Amount    int64  `json:"amount" validate:"required,min=1"`
+	Reference string `json:"reference" validate:"max=200"`
+}
+
+//	grantCoinsHandler godoc
+//
+//	@Summary		Grant coins
+//	@Description	Add coins to a user's balance as an admin grant, a bonus or a refund. Recorded in the coin ledger and the audit log. Requires coins:manage
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			userID	path		int					true	"User ID"
+//	@Param			payload	body		GrantCoinsPayload	true	"Grant"
+//	@Success		201		{object}	store.CoinTransaction
+//	@Failure		400		{object}	swagger.EnvelopeError	"Invalid request"
+//	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		403		{object}	swagger.EnvelopeError	"Forbidden"
+//	@Failure		404		{object}	swagger.EnvelopeError	"User not found"
+//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/admin/users/{userID}/coins [post]
+func (app *application) grantCoinsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload GrantCoinsPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	txn := &store.CoinTransaction{
+		UserID:    userID,
+		Kind:      payload.Kind,
+		Amount:    payload.Amount,
+		Reference: payload.Reference,
+	}
+
+	if err := app.store.Coins.Grant(r.Context(), txn); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, txn); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+//	reconcileCoinsHandler godoc
+//
+//	@Summary		Reconcile coin balances
+//	@Description	List the users whose cached coin balance differs from the sum of their coin ledger. An empty list means the ledger and balances agree. Requires coins:manage
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		store.CoinMismatch
+//	@Failure		401	{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		403	{object}	swagger.EnvelopeError	"Forbidden"
+//	@Failure		500	{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/admin/coins/reconcile [get]
+func (app *application) reconcileCoinsHandler(w http.ResponseWriter, r *http.Request) {
+	mismatches, err := app.store.Coins.Reconcile(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, mismatches); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -1,6 +1,11 @@
 package main
 
-import "net/http"
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
 
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
 	app.logger.Errorw("internal error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
@@ -32,8 +37,22 @@ func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request
 	writeJSONError(w, http.StatusForbidden, "prohibited")
 }
 
+func (app *application) twoFactorRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	app.logger.Warnf("forbidden error", "method", r.Method, "path", r.URL.Path, "error", "two-factor authentication required")
+
+	writeJSONError(w, http.StatusForbidden, "enable two-factor authentication to use this endpoint")
+}
+
 func (app *application) paymentRequiredResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logger.Warnf("payment required error", "method", r.Method, "path", r.URL.Path, "error", "prohibited")
 
 	writeJSONError(w, http.StatusPaymentRequired, err.Error())
 }
+
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path, "retry_after", retryAfter.String())
+
+	w.Header().Set("Retry-After", fmt.Sprintf("%.f", math.Ceil(retryAfter.Seconds())))
+
+	writeJSONError(w, http.StatusTooManyRequests, "too many attempts, retry after: "+retryAfter.Round(time.Second).String())
+}
@@ -21,7 +21,7 @@ type GenrePayload struct {
 // createGenreHandler godoc
 //
 //	@Summary		Create a new genre
-//	@Description	Create a new genre. Admin only
+//	@Description	Create a new genre. Requires genres:write
 //	@Tags			genres
 //	@Accept			json
 //	@Produce		json
@@ -85,7 +85,7 @@ func (app *application) getAllGenreHandler(w http.ResponseWriter, r *http.Reques
 // updateGenreHandler godoc
 //
 //	@Summary		update genre
-//	@Description	update genre. Admin only
+//	@Description	update genre. Requires genres:write
 //	@Tags			genres
 //	@Accept			json
 //	@Produce		json
@@ -133,7 +133,7 @@ func (app *application) updateGenreHandler(w http.ResponseWriter, r *http.Reques
 // deleteNovelHandler godoc
 //
 //	@Summary		Delete genre
-//	@Description	Delete genre by ID. Admin only
+//	@Description	Delete genre by ID. Requires genres:write
 //	@Tags			genres
 //	@Accept			json
 //	@Produce		json
@@ -1,53 +1,50 @@
 package main
 
 import (
-	"context"
 	"errors"
 	"net/http"
 
+	"github.com/AlfanDutaPamungkas/Govel/internal/payments"
 	"github.com/AlfanDutaPamungkas/Govel/internal/store"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
-	"github.com/xendit/xendit-go/v6/invoice"
 )
 
 //	createInvoiceHandler godoc
 //
 //	@Summary		Create invoice
-//	@Description	Get invoices according from plan
+//	@Description	Create an invoice for a coin plan on sale. The invoice keeps the plan's price and coins at this moment, so later changes to the plan don't affect it
 //	@Tags			invoices
 //	@Produce		json
 //	@Security		BearerAuth
-//	@Param			plan	path		string					true	"Plan"
+//	@Param			plan	path		string					true	"Plan code"
 //	@Success		201		{object}	store.Invoice			"Create invoice successfully"
+//	@Failure		400		{object}	swagger.EnvelopeError	"Plan not found"
 //	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
 //	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
 //	@Router			/invoices/{plan} [post]
 func (app *application) createInvoiceHandler(w http.ResponseWriter, r *http.Request) {
 	user := getUserFromCtx(r)
-	plan := chi.URLParam(r, "plan")
-
-	var amount float64
-
-	if plan == "lite" {
-		amount = 15000
-	} else if plan == "scroll" {
-		amount = 65000
-	} else if plan == "volume" {
-		amount = 100000
-	} else {
-		app.badRequestResponse(w, r, errors.New("plan not found"))
+
+	plan, err := app.store.CoinPlans.GetActiveByCode(r.Context(), chi.URLParam(r, "plan"))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestResponse(w, r, errors.New("plan not found"))
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
 	externalID := "invoice-" + uuid.New().String()
 
-	invReq := *invoice.NewCreateInvoiceRequest(externalID, amount)
-
-	resp, _, err := app.xendit.InvoiceApi.CreateInvoice(context.Background()).
-		CreateInvoiceRequest(invReq).
-		Execute()
-
+	resp, err := app.payments.CreateInvoice(r.Context(), payments.InvoiceRequest{
+		ExternalID:  externalID,
+		Amount:      plan.Price,
+		Currency:    plan.Currency,
+		Description: plan.Name,
+	})
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -56,11 +53,14 @@ func (app *application) createInvoiceHandler(w http.ResponseWriter, r *http.Requ
 	i := &store.Invoice{
 		UserID:     user.ID,
 		ExternalID: externalID,
-		InvoiceID:  *resp.Id,
-		Status:     string(resp.Status),
-		Amount:     amount,
-		Plan:       plan,
-		InvoiceURL: resp.InvoiceUrl,
+		InvoiceID:  resp.ID,
+		Status:     resp.Status,
+		Amount:     plan.Price,
+		Currency:   plan.Currency,
+		Plan:       plan.Code,
+		Coins:      plan.Coins,
+		BonusCoins: plan.BonusCoins,
+		InvoiceURL: resp.URL,
 	}
 
 	if err := app.store.Invoices.Create(r.Context(), i); err != nil {
@@ -108,7 +108,7 @@ func (app *application) getInvoiceHandler(w http.ResponseWriter, r *http.Request
 //	getAllInvoicesHandler godoc
 //
 //	@Summary		Get all invoices
-//	@Description	Get all invoices. Admin only
+//	@Description	Get all invoices. Requires invoices:read
 //	@Tags			invoices
 //	@Produce		json
 //	@Security		BearerAuth
@@ -3,6 +3,8 @@ package main
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
+	"unicode"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -11,6 +13,15 @@ var Validate *validator.Validate
 
 func init() {
 	Validate = validator.New(validator.WithRequiredStructEnabled())
+	Validate.RegisterValidation("printable", validatePrintable)
+}
+
+// validatePrintable rejects control characters, such as line breaks, in text
+// that ends up in email subjects and page titles.
+func validatePrintable(fl validator.FieldLevel) bool {
+	return !strings.ContainsFunc(fl.Field().String(), func(r rune) bool {
+		return !unicode.IsPrint(r)
+	})
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) error {
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/AlfanDutaPamungkas/Govel/internal/auth"
@@ -8,8 +11,9 @@ import (
 	"github.com/AlfanDutaPamungkas/Govel/internal/db"
 	"github.com/AlfanDutaPamungkas/Govel/internal/env"
 	"github.com/AlfanDutaPamungkas/Govel/internal/mailer"
+	"github.com/AlfanDutaPamungkas/Govel/internal/payments"
+	"github.com/AlfanDutaPamungkas/Govel/internal/ratelimiter"
 	"github.com/AlfanDutaPamungkas/Govel/internal/store"
-	"github.com/xendit/xendit-go/v6"
 	"go.uber.org/zap"
 )
 
@@ -55,12 +59,81 @@ func main() {
 				password: env.GetEnv("SMTP_PASSWORD", ""),
 			},
 		},
+		activation: activationConfig{
+			resendMaxRequests: 3,
+			resendBackoff:     time.Minute,
+			resendMaxBackoff:  time.Hour,
+			gracePeriod:       time.Hour * 24 * time.Duration(env.GetIntEnv("UNACTIVATED_USER_GRACE_DAYS", 7)),
+			sweepInterval:     time.Hour,
+		},
+		deletion: deletionConfig{
+			gracePeriod: time.Hour * 24 * time.Duration(env.GetIntEnv("ACCOUNT_DELETION_GRACE_DAYS", 30)),
+		},
+		chapter: chapterConfig{
+			releaseInterval: time.Second * time.Duration(env.GetIntEnv("CHAPTER_RELEASE_INTERVAL_SECONDS", 60)),
+			mailWorkers:     max(env.GetIntEnv("CHAPTER_RELEASE_MAIL_WORKERS", 4), 1),
+		},
+		search: searchConfig{
+			suggestCacheSize: env.GetIntEnv("SEARCH_SUGGEST_CACHE_SIZE", 1000),
+			suggestCacheTTL:  time.Second * time.Duration(env.GetIntEnv("SEARCH_SUGGEST_CACHE_TTL_SECONDS", 60)),
+		},
 		frontendURL: env.GetEnv("FRONTEND_URL", "http://localhost:5173"),
 		auth: authConfig{
 			token: tokenConfig{
-				secret: env.GetEnv("AUTH_TOKEN_SECRET", ""),
-				exp:    time.Hour * 24 * 3,
-				iss:    "govel",
+				secret:     env.GetEnv("AUTH_TOKEN_SECRET", ""),
+				keysDir:    env.GetEnv("AUTH_KEYS_DIR", ""),
+				signingKid: env.GetEnv("AUTH_SIGNING_KID", ""),
+				exp:        time.Minute * 15,
+				iss:        "govel",
+			},
+			refresh: refreshConfig{
+				exp: time.Hour * 24 * 30,
+			},
+			lockout: lockoutConfig{
+				maxAttempts:   env.GetIntEnv("LOGIN_MAX_ATTEMPTS", 5),
+				duration:      time.Minute * 15,
+				ipMaxAttempts: env.GetIntEnv("LOGIN_IP_MAX_ATTEMPTS", 20),
+				ipBackoff:     time.Second,
+				ipMaxBackoff:  time.Hour,
+			},
+			forgot: forgotPasswordConfig{
+				maxRequests:   env.GetIntEnv("FORGOT_PASSWORD_MAX_REQUESTS", 3),
+				ipMaxRequests: env.GetIntEnv("FORGOT_PASSWORD_IP_MAX_REQUESTS", 10),
+				backoff:       time.Minute,
+				maxBackoff:    time.Hour,
+			},
+			twoFactor: twoFactorConfig{
+				issuer:            "Govel",
+				challengeExp:      time.Minute * 5,
+				requiredForAdmins: env.GetBoolEnv("TWO_FACTOR_REQUIRED_FOR_ADMINS", false),
+			},
+			oidc: oidcConfig{
+				stateExp: time.Minute * 10,
+				providers: []oidcProviderConfig{
+					{
+						name:         env.GetEnv("OIDC_PROVIDER", "google"),
+						issuer:       env.GetEnv("OIDC_ISSUER_URL", ""),
+						clientID:     env.GetEnv("OIDC_CLIENT_ID", ""),
+						clientSecret: env.GetEnv("OIDC_CLIENT_SECRET", ""),
+						redirectURL:  env.GetEnv("OIDC_REDIRECT_URL", ""),
+					},
+				},
+			},
+			password: passwordConfig{
+				minLength:     env.GetIntEnv("PASSWORD_MIN_LENGTH", 8),
+				requireUpper:  env.GetBoolEnv("PASSWORD_REQUIRE_UPPER", false),
+				requireLower:  env.GetBoolEnv("PASSWORD_REQUIRE_LOWER", false),
+				requireDigit:  env.GetBoolEnv("PASSWORD_REQUIRE_DIGIT", false),
+				requireSymbol: env.GetBoolEnv("PASSWORD_REQUIRE_SYMBOL", false),
+				bannedList:    env.GetEnv("PASSWORD_BANNED_LIST", ""),
+				breachedList:  env.GetEnv("PASSWORD_BREACHED_LIST", ""),
+				hash: passwordHashConfig{
+					algorithm:         env.GetEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+					bcryptCost:        env.GetIntEnv("PASSWORD_BCRYPT_COST", 12),
+					argon2Memory:      env.GetIntEnv("PASSWORD_ARGON2_MEMORY_KB", 64*1024),
+					argon2Iterations:  env.GetIntEnv("PASSWORD_ARGON2_ITERATIONS", 3),
+					argon2Parallelism: env.GetIntEnv("PASSWORD_ARGON2_PARALLELISM", 2),
+				},
 			},
 		},
 		cloudinaryConfig: &cld.CloudinaryConfig{
@@ -68,7 +141,12 @@ func main() {
 			APIKey:    env.GetEnv("API_KEY", ""),
 			APISecret: env.GetEnv("API_SECRET", ""),
 		},
-		xenditSecret: env.GetEnv("XENDIT_SECRET_KEY", ""),
+		paymentProvider: env.GetEnv("PAYMENT_PROVIDER", "xendit"),
+		xendit: xenditConfig{
+			secretKey:     env.GetEnv("XENDIT_SECRET_KEY", ""),
+			callbackToken: env.GetEnv("XENDIT_CALLBACK_TOKEN", ""),
+		},
+		trustedProxies: env.GetEnv("TRUSTED_PROXIES", ""),
 	}
 
 	logger := zap.Must(zap.NewProduction()).Sugar()
@@ -87,6 +165,24 @@ func main() {
 	defer db.Close()
 	logger.Info("db connection pool established")
 
+	proxies, err := parseProxies(cfg.trustedProxies)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	switch hash := cfg.auth.password.hash; hash.algorithm {
+	case "argon2id":
+		store.DefaultPasswordHasher = store.NewArgon2idHasher(
+			uint32(hash.argon2Memory),
+			uint32(hash.argon2Iterations),
+			uint8(hash.argon2Parallelism),
+		)
+	case "bcrypt":
+		store.DefaultPasswordHasher = store.NewBcryptHasher(hash.bcryptCost)
+	default:
+		logger.Fatalf("unknown PASSWORD_HASH_ALGORITHM %q", hash.algorithm)
+	}
+
 	store := store.NewStorage(db)
 
 	mailer := mailer.NewSMTPMailer(
@@ -96,12 +192,25 @@ func main() {
 		cfg.mail.smtp.password,
 	)
 
-	jwtAuthenticator := auth.NewJWTAuthenticator(
+	var authenticator auth.Authenticator = auth.NewJWTAuthenticator(
 		cfg.auth.token.secret,
 		cfg.auth.token.iss,
 		cfg.auth.token.iss,
 	)
 
+	if cfg.auth.token.keysDir != "" {
+		authenticator, err = auth.NewKeySetAuthenticator(
+			cfg.auth.token.keysDir,
+			cfg.auth.token.signingKid,
+			cfg.auth.token.iss,
+			cfg.auth.token.iss,
+		)
+
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
 	cld, err := cld.NewCloudinary(
 		cfg.cloudinaryConfig.CloudName,
 		cfg.cloudinaryConfig.APIKey,
@@ -112,18 +221,107 @@ func main() {
 		logger.Fatal(err)
 	}
 
-	xnd := xendit.NewClient(cfg.xenditSecret)
+	var provider payments.Provider
+	switch cfg.paymentProvider {
+	case "xendit":
+		if cfg.xendit.callbackToken == "" {
+			logger.Warn("XENDIT_CALLBACK_TOKEN is not set, every Xendit callback will be refused")
+		}
+
+		provider = payments.NewXenditProvider(cfg.xendit.secretKey, cfg.xendit.callbackToken)
+	case "fake":
+		if strings.EqualFold(cfg.env, "production") {
+			logger.Fatal("PAYMENT_PROVIDER=fake is not allowed in production")
+		}
+
+		provider = payments.NewFakeProvider(
+			fmt.Sprintf("http://%s/v1/payments/fake", cfg.apiURL),
+			fmt.Sprintf("http://%s/v1/webhook", cfg.apiURL),
+			cfg.frontendURL+"/transaction-history",
+		)
+		logger.Warn("using the fake payment provider, no real payments are taken")
+	default:
+		logger.Fatalf("unknown PAYMENT_PROVIDER %q", cfg.paymentProvider)
+	}
+
+	passwords := auth.NewPasswordPolicy(
+		cfg.auth.password.minLength,
+		cfg.auth.password.requireUpper,
+		cfg.auth.password.requireLower,
+		cfg.auth.password.requireDigit,
+		cfg.auth.password.requireSymbol,
+	)
+
+	if cfg.auth.password.bannedList != "" {
+		if err := passwords.LoadBannedList(cfg.auth.password.bannedList); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	if cfg.auth.password.breachedList != "" {
+		breached, err := auth.LoadBreachedPasswords(cfg.auth.password.breachedList)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		passwords.SetBreachedPasswords(breached)
+		logger.Infow("breached password list loaded", "hashes", breached.Len())
+	}
 
 	app := &application{
 		config:        cfg,
 		logger:        logger,
 		store:         store,
 		mailer:        mailer,
-		authenticator: jwtAuthenticator,
+		authenticator: authenticator,
 		cld:           cld,
-		xendit:        xnd,
+		payments:      provider,
+		loginLimiter: ratelimiter.NewBackoff(
+			cfg.auth.lockout.ipMaxAttempts,
+			cfg.auth.lockout.ipBackoff,
+			cfg.auth.lockout.ipMaxBackoff,
+		),
+		resendLimiter: ratelimiter.NewBackoff(
+			cfg.activation.resendMaxRequests,
+			cfg.activation.resendBackoff,
+			cfg.activation.resendMaxBackoff,
+		),
+		forgotLimiter: ratelimiter.NewBackoff(
+			cfg.auth.forgot.maxRequests,
+			cfg.auth.forgot.backoff,
+			cfg.auth.forgot.maxBackoff,
+		),
+		forgotIPLimiter: ratelimiter.NewBackoff(
+			cfg.auth.forgot.ipMaxRequests,
+			cfg.auth.forgot.backoff,
+			cfg.auth.forgot.maxBackoff,
+		),
+		oidcProviders: make(map[string]*auth.OIDCProvider),
+		passwords:     passwords,
+		suggestions:   newSuggestionCache(cfg.search),
+		proxies:       proxies,
+
+		releaseNotices: make(chan chapterNotice, releaseNoticeQueueSize),
 	}
 
+	for _, p := range cfg.auth.oidc.providers {
+		if p.issuer == "" || p.clientID == "" {
+			continue
+		}
+
+		redirectURL := p.redirectURL
+		if redirectURL == "" {
+			redirectURL = fmt.Sprintf("http://%s/v1/authentication/oidc/%s/callback", cfg.apiURL, p.name)
+		}
+
+		app.oidcProviders[p.name] = auth.NewOIDCProvider(p.name, p.issuer, p.clientID, p.clientSecret, redirectURL)
+		logger.Infow("social login enabled", "provider", p.name)
+	}
+
+	go app.sweep(context.Background())
+	go app.releaseChapters(context.Background())
+	go app.sendReleaseEmails(context.Background())
+
 	mux := app.mount()
 
 	app.run(mux)
@@ -7,11 +7,22 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/AlfanDutaPamungkas/Govel/internal/store"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// peerAddrMiddleware keeps the address of the connection before
+// middleware.RealIP replaces it with the forwarded one.
+func peerAddrMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), peerCtx, r.RemoteAddr)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -59,26 +70,68 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		sessionID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sid"]), 10, 64)
+		if err != nil {
+			app.unauthorizedResponse(w, r, err)
+			return
+		}
+
+		session, err := app.store.Sessions.Touch(ctx, sessionID, user.ID, clientIP(r))
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.unauthorizedResponse(w, r, errors.New("session revoked"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
 		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, sessionCtx, session)
+		ctx = store.WithAuditActor(ctx, user.ID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// auditMetaMiddleware tags the request context with what the store needs to
+// attribute audit events.
+func (app *application) auditMetaMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := store.WithAuditMeta(r.Context(), store.AuditMeta{
+			RequestID: middleware.GetReqID(r.Context()),
+			IP:        clientIP(r),
+		})
+
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func (app *application) AdminOnly() func(http.Handler) http.Handler {
+// RequirePermission only lets users through whose role grants every listed
+// permission.
+func (app *application) RequirePermission(permissions ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			user := getUserFromCtx(r)
 
-			if user.Role != "admin" {
+			if !user.HasPermission(permissions...) {
 				app.forbiddenResponse(w, r)
 				return
 			}
 
+			if app.config.auth.twoFactor.requiredForAdmins && user.IsStaff() && !user.TOTPEnabled {
+				app.twoFactorRequiredResponse(w, r)
+				return
+			}
+
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// CheckPremium must run after chaptersContextMiddleware, which loads the
+// chapter with the lock state of right now, so early access chapters pass
+// once they are free.
 func (app *application) CheckPremium() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -90,7 +143,7 @@ func (app *application) CheckPremium() func(http.Handler) http.Handler {
 				return
 			}
 
-			if user.Role == "admin" {
+			if user.HasPermission(store.PermissionChaptersReadLocked) {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -98,6 +151,8 @@ func (app *application) CheckPremium() func(http.Handler) http.Handler {
 			err := app.store.UserUnlocks.CheckUser(r.Context(), user.ID, chapter.Slug)
 			if err != nil {
 				switch {
+				case errors.Is(err, store.ErrNotFound) && chapter.FreeAt != nil:
+					app.paymentRequiredResponse(w, r, fmt.Errorf("please purchase this chapter or wait until it is free at %s", chapter.FreeAt.Format(time.RFC3339)))
 				case errors.Is(err, store.ErrNotFound):
 					app.paymentRequiredResponse(w, r, errors.New("please purchase this chapter"))
 				default:
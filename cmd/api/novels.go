@@ -3,8 +3,11 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	cld "github.com/AlfanDutaPamungkas/Govel/internal/cloudinary"
@@ -17,23 +20,25 @@ type novelKey string
 const novelCtx novelKey = "novel"
 
 type CreateNovelPayload struct {
-	Title    string  `schema:"title" validate:"required"`
-	Author   string  `schema:"author" validate:"required,max=255"`
+	Title    string  `schema:"title" validate:"required,max=255,printable"`
+	Author   string  `schema:"author" validate:"max=255"`
 	Synopsis string  `schema:"synopsis" validate:"required"`
 	GenreIDs []int32 `schema:"genre_ids" validate:"required,min=1,dive,gt=0"`
+	Language string  `schema:"language" validate:"omitempty,oneof=english indonesian simple"`
 }
 
 // createNovelHandler godoc
 //
 //	@Summary		Create a new novel
-//	@Description	Create a new novel with title, author, synopsis, genre, and optional image. Admin only
+//	@Description	Create a new draft novel owned by the current user with title, author, synopsis, genre, and optional image. Author is the username; only staff with novels:manage_any may set another. Publish it with PATCH /novels/{novelID}/status. Requires novels:write
 //	@Tags			novels
 //	@Accept			multipart/form-data
 //	@Produce		json
 //	@Param			title		formData	string	true	"Novel Title"
-//	@Param			author		formData	string	true	"Author of the Novel"
+//	@Param			author		formData	string	false	"Author of the Novel, staff only"
 //	@Param			synopsis	formData	string	true	"Synopsis of the Novel"
 //	@Param			genre_ids	formData	[]int	true	"Genre IDs (multiple values allowed)"
+//	@Param			language	formData	string	false	"Search language: english (default), indonesian or simple"
 //	@Param			image		formData	file	false	"Cover image of the Novel"
 //	@Security		BearerAuth
 //	@Success		201	{object}	store.Novel				"Novel created successfully"
@@ -44,6 +49,7 @@ type CreateNovelPayload struct {
 //	@Router			/novels [post]
 func (app *application) createNovelHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	user := getUserFromCtx(r)
 
 	file, fileHeader, err := r.FormFile("image")
 	if err != nil {
@@ -77,11 +83,18 @@ func (app *application) createNovelHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Authors publish under their own name, only staff credit someone else
+	if payload.Author == "" || !user.HasPermission(store.PermissionNovelsManageAny) {
+		payload.Author = user.Username
+	}
+
 	novel := &store.Novel{
 		Title:    payload.Title,
 		Author:   payload.Author,
+		OwnerID:  &user.ID,
 		Synopsis: payload.Synopsis,
 		ImageURL: imageUrl,
+		Language: payload.Language,
 	}
 
 	if err := app.store.Novels.CreateNovelAndInsertGenres(ctx, novel, payload.GenreIDs); err != nil {
@@ -101,16 +114,17 @@ func (app *application) createNovelHandler(w http.ResponseWriter, r *http.Reques
 }
 
 type UpdateNovelPayload struct {
-	Title    string  `json:"title"`
+	Title    string  `json:"title" validate:"omitempty,max=255,printable"`
 	Author   string  `json:"author" validate:"omitempty,max=255"`
 	Synopsis string  `json:"synopsis"`
 	GenreIDs []int32 `json:"genre_ids"`
+	Language string  `json:"language" validate:"omitempty,oneof=english indonesian simple"`
 }
 
 // updateNovelHandler godoc
 //
 //	@Summary		Update novel
-//	@Description	Update an existing novel's title, author, synopsis, or genre. Admin only.
+//	@Description	Update an existing novel's title, author, synopsis, genre, or search language. Requires novels:write and owning the novel. Only staff with novels:manage_any may change the author; other owners stay credited under their username.
 //	@Tags			novels
 //	@Accept			json
 //	@Produce		json
@@ -127,6 +141,7 @@ type UpdateNovelPayload struct {
 //	@Router			/novels/{novelID} [patch]
 func (app *application) updateNovelHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	user := getUserFromCtx(r)
 	novel := getNovelFromCtx(r)
 
 	var payload UpdateNovelPayload
@@ -140,7 +155,7 @@ func (app *application) updateNovelHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if payload.Title == "" && payload.Author == "" && payload.Synopsis == "" && len(payload.GenreIDs) == 0 {
+	if payload.Title == "" && payload.Author == "" && payload.Synopsis == "" && len(payload.GenreIDs) == 0 && payload.Language == "" {
 		app.badRequestResponse(w, r, errors.New("please provide at least one field"))
 		return
 	}
@@ -149,14 +164,23 @@ func (app *application) updateNovelHandler(w http.ResponseWriter, r *http.Reques
 		novel.Title = payload.Title
 	}
 
-	if payload.Author != "" {
-		novel.Author = payload.Author
+	if user.HasPermission(store.PermissionNovelsManageAny) {
+		if payload.Author != "" {
+			novel.Author = payload.Author
+		}
+	} else {
+		// Only the owner gets here, so the novel stays credited to them
+		novel.Author = user.Username
 	}
 
 	if payload.Synopsis != "" {
 		novel.Synopsis = payload.Synopsis
 	}
 
+	if payload.Language != "" {
+		novel.Language = payload.Language
+	}
+
 	if len(payload.GenreIDs) != 0 {
 		if err := app.store.Novels.UpdateNovelGenres(ctx, novel.ID, payload.GenreIDs); err != nil {
 			app.internalServerError(w, r, err)
@@ -185,7 +209,7 @@ func (app *application) updateNovelHandler(w http.ResponseWriter, r *http.Reques
 // changeNovelImageHandler godoc
 //
 //	@Summary		Change novel image
-//	@Description	Update the cover image of a novel. Admin only.
+//	@Description	Update the cover image of a novel. Requires novels:write and owning the novel.
 //	@Tags			novels
 //	@Accept			mpfd
 //	@Produce		json
@@ -237,10 +261,66 @@ func (app *application) changeNovelImageHandler(w http.ResponseWriter, r *http.R
 	}
 }
 
+type NovelStatusPayload struct {
+	Status      string     `json:"status" validate:"required,oneof=draft scheduled published hiatus completed"`
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+// setNovelStatusHandler godoc
+//
+//	@Summary		Change novel status
+//	@Description	Move a novel through its lifecycle. draft can be scheduled or published, scheduled can be rescheduled, published or returned to draft, and published, hiatus and completed can move between each other. Scheduling needs a future published_at; publishing a draft may backdate it and otherwise publishes now. Requires novels:write and owning the novel
+//	@Tags			novels
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			novelID	path		int						true	"Novel ID"
+//	@Param			data	body		NovelStatusPayload		true	"New status"
+//	@Success		200		{object}	store.Novel				"Updated novel"
+//	@Failure		400		{object}	swagger.EnvelopeError	"Invalid status, transition or time"
+//	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		403		{object}	swagger.EnvelopeError	"Forbidden"
+//	@Failure		404		{object}	swagger.EnvelopeError	"Novel not found"
+//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/novels/{novelID}/status [patch]
+func (app *application) setNovelStatusHandler(w http.ResponseWriter, r *http.Request) {
+	novel := getNovelFromCtx(r)
+
+	var payload NovelStatusPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Novels.SetStatus(r.Context(), novel, payload.Status, payload.PublishedAt); err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidStatusTransition):
+			app.badRequestResponse(w, r, fmt.Errorf("%w: %s to %s", err, novel.Status, payload.Status))
+		case errors.Is(err, store.ErrInvalidPublishTime):
+			app.badRequestResponse(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, novel); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
 // deleteNovelHandler godoc
 //
 //	@Summary		Delete novel
-//	@Description	Delete novel by ID. Admin only
+//	@Description	Delete novel by ID. Requires novels:delete and owning the novel
 //	@Tags			novels
 //	@Accept			json
 //	@Produce		json
@@ -277,7 +357,7 @@ func (app *application) deleteNovelHandler(w http.ResponseWriter, r *http.Reques
 // getNovelHandler godoc
 //
 //	@Summary		Get novel detail
-//	@Description	Get detailed information about a specific novel by its ID, including chapters
+//	@Description	Get detailed information about a specific novel by its ID, including chapters. Scheduled chapters are only listed for those who manage the novel
 //	@Tags			novels
 //	@Produce		json
 //	@Param			novelID	path	int	true	"Novel ID"
@@ -293,7 +373,7 @@ func (app *application) getNovelHandler(w http.ResponseWriter, r *http.Request)
 	novel := getNovelFromCtx(r)
 	ctx := r.Context()
 
-	chapters, err := app.store.Chapters.GetChaptersFromNovelID(ctx, novel.ID, user.ID)
+	chapters, err := app.store.Chapters.GetChaptersFromNovelID(ctx, novel.ID, user.ID, user.CanManageNovel(novel))
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -315,37 +395,156 @@ func (app *application) getNovelHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// readNovelFilter reads the query parameters shared by the novel, genre and
+// bookmark listings.
+func readNovelFilter(r *http.Request) (store.NovelFilter, error) {
+	query := r.URL.Query()
+
+	filter := store.NovelFilter{
+		Search:     query.Get("search"),
+		InChapters: query.Get("in_chapters") == "true",
+		Author:     query.Get("author"),
+		Status:     query.Get("status"),
+		Sort:       query.Get("sort_by"),
+		Order:      query.Get("order"),
+		Cursor:     query.Get("cursor"),
+
+		IncludeTotal: query.Get("include_total") == "true",
+	}
+
+	if filter.Status != "" && !slices.Contains(store.NovelStatuses, filter.Status) {
+		return filter, fmt.Errorf("status must be one of %s", strings.Join(store.NovelStatuses, ", "))
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > store.MaxPageLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", store.MaxPageLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if v := query.Get("genres"); v != "" {
+		for _, s := range strings.Split(v, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 32)
+			if err != nil {
+				return filter, fmt.Errorf("invalid genre id %q", s)
+			}
+			filter.GenreIDs = append(filter.GenreIDs, int32(id))
+		}
+	}
+
+	return filter, nil
+}
+
 // getAllNovelHandler godoc
 //
 //	@Summary		Get all novels
-//	@Description	Get all novels not including chapters
+//	@Description	Get a page of published novels not including chapters. Pass next_cursor back as cursor for the next page. Searches match title, author and synopsis by word prefix in any search language, tolerate typos in titles and authors, and return a highlighted synopsis headline
 //	@Tags			novels
 //	@Produce		json
-//	@Param			sorted_by	query		string					false	"Sort by created_at or updated_at"
-//	@Param			search		query		string					false	"Search by title"
-//	@Success		200			{array}		store.Novel				"Get all Novels successfully"
-//	@Failure		500			{object}	swagger.EnvelopeError	"Internal server error"
+//	@Param			search		query		string								false	"Search title, author and synopsis"
+//	@Param			in_chapters	query		bool								false	"Also search chapter text"
+//	@Param			author		query		string								false	"Exact author name, ignoring case"
+//	@Param			genres		query		string								false	"Comma separated genre IDs, novels must have all of them"
+//	@Param			status		query		string								false	"published, hiatus or completed"
+//	@Param			sort_by		query		string								false	"relevance (default when searching), updated_at (default), created_at, popularity, title or chapters"
+//	@Param			order		query		string								false	"asc or desc, defaults to asc for title and desc otherwise"
+//	@Param			cursor		query		string								false	"next_cursor from the previous page"
+//	@Param			limit		query		int									false	"Page size, default 20, at most 100"
+//	@Param			include_total	query		bool								false	"Count every match in total"
+//	@Success		200			{object}	store.Page[store.Novel]				"Get all Novels successfully"
+//	@Failure		400			{object}	swagger.EnvelopeError				"Invalid filter or cursor"
+//	@Failure		500			{object}	swagger.EnvelopeError				"Internal server error"
 //	@Router			/novels [get]
 func (app *application) getAllNovelHandler(w http.ResponseWriter, r *http.Request) {
-	sortBy := r.URL.Query().Get("sort_by")
-	search := r.URL.Query().Get("search")
+	filter, err := readNovelFilter(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
 
-	if sortBy != "" && sortBy != "created_at" && sortBy != "updated_at" {
-		app.notFoundResponse(w, r, errors.New("invalid sort_by option"))
+	page, err := app.store.Novels.List(r.Context(), filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidOption), errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
 		return
 	}
+}
 
-	novels, err := app.store.Novels.GetAllNovel(r.Context(), sortBy, search)
+// getAllNovelAdminHandler godoc
+//
+//	@Summary		Get all novels for staff
+//	@Description	Like GET /novels but includes draft and scheduled novels. Requires novels:manage_any
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			status	query		string						false	"draft, scheduled, published, hiatus or completed"
+//	@Param			sort_by	query		string						false	"updated_at (default), created_at, popularity, title or chapters"
+//	@Param			cursor	query		string						false	"next_cursor from the previous page"
+//	@Param			limit	query		int							false	"Page size, default 20, at most 100"
+//	@Param			include_total	query	bool						false	"Count every match in total"
+//	@Success		200		{object}	store.Page[store.Novel]
+//	@Failure		400		{object}	swagger.EnvelopeError		"Invalid filter or cursor"
+//	@Failure		401		{object}	swagger.EnvelopeError		"Unauthorize"
+//	@Failure		403		{object}	swagger.EnvelopeError		"Forbidden"
+//	@Failure		500		{object}	swagger.EnvelopeError		"Internal server error"
+//	@Router			/admin/novels [get]
+func (app *application) getAllNovelAdminHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := readNovelFilter(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	filter.IncludeUnpublished = true
+
+	page, err := app.store.Novels.List(r.Context(), filter)
 	if err != nil {
 		switch {
-		case errors.Is(err, store.ErrInvalidOption):
-			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrInvalidOption), errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
 
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// getOwnNovelsHandler godoc
+//
+//	@Summary		Get my novels
+//	@Description	Get the novels owned by the current user
+//	@Tags			users
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		store.Novel				"Novels owned by the user"
+//	@Failure		401	{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		500	{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/users/novels [get]
+func (app *application) getOwnNovelsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	novels, err := app.store.Novels.GetByOwnerID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, novels); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -355,23 +554,42 @@ func (app *application) getAllNovelHandler(w http.ResponseWriter, r *http.Reques
 // getNovelsFromGenreID godoc
 //
 //	@Summary		Get novels from genre name
-//	@Description	Get novels from genre name
+//	@Description	Get a page of novels in a genre. Accepts the same filters as GET /novels
 //	@Tags			novels
 //	@Produce		json
-//	@Param			genreID	path		int						true	"Get from genre"
-//	@Success		200		{array}		store.Novel				"Get novels from genre successfully"
-//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Param			genreID	path		int							true	"Get from genre"
+//	@Param			genres	query		string						false	"More genre IDs the novels must also have"
+//	@Param			sort_by	query		string						false	"updated_at (default), created_at, popularity, title or chapters"
+//	@Param			cursor	query		string						false	"next_cursor from the previous page"
+//	@Param			limit	query		int							false	"Page size, default 20, at most 100"
+//	@Param			include_total	query	bool						false	"Count every match in total"
+//	@Success		200		{object}	store.Page[store.Novel]		"Get novels from genre successfully"
+//	@Failure		400		{object}	swagger.EnvelopeError		"Invalid filter or cursor"
+//	@Failure		500		{object}	swagger.EnvelopeError		"Internal server error"
 //	@Router			/genres/{genreID}/novels [get]
 func (app *application) getNovelsFromGenreID(w http.ResponseWriter, r *http.Request) {
 	genre := getGenreFromCtx(r)
 
-	novels, err := app.store.Novels.GetNovelsFromGenreID(r.Context(), genre.ID)
+	filter, err := readNovelFilter(r)
 	if err != nil {
-		app.internalServerError(w, r, err)
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, novels); err != nil {
+	filter.GenreIDs = append(filter.GenreIDs, genre.ID)
+
+	page, err := app.store.Novels.List(r.Context(), filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidOption), errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -399,11 +617,34 @@ func (app *application) novelsContextMiddleware(next http.Handler) http.Handler
 			return
 		}
 
+		if !novel.IsPublic() && !user.CanManageNovel(novel) {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, novelCtx, novel)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// NovelOwnerOnly must run after novelsContextMiddleware. It restricts writes
+// to the novel's author unless the user may manage every novel.
+func (app *application) NovelOwnerOnly() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := getUserFromCtx(r)
+			novel := getNovelFromCtx(r)
+
+			if !user.CanManageNovel(novel) {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func getNovelFromCtx(r *http.Request) *store.Novel {
 	novel, _ := r.Context().Value(novelCtx).(*store.Novel)
 	return novel
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/AlfanDutaPamungkas/Govel/internal/auth"
+	"github.com/AlfanDutaPamungkas/Govel/internal/mailer"
+	"github.com/AlfanDutaPamungkas/Govel/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Error codes handed to the frontend in the callback fragment.
+const (
+	oidcErrInvalidState       = "invalid_state"
+	oidcErrLoginFailed        = "login_failed"
+	oidcErrEmailNotVerified   = "email_not_verified"
+	oidcErrActivationRequired = "activation_required"
+	oidcErrAccountInactive    = "account_inactive"
+	oidcErrAlreadyLinked      = "already_linked"
+)
+
+var usernameDisallowed = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// oidcLoginError is a failure the reader can act on, as opposed to an
+// internal error.
+type oidcLoginError struct {
+	code string
+}
+
+func (e *oidcLoginError) Error() string {
+	return "oidc login: " + e.code
+}
+
+//	oidcLoginHandler godoc
+//
+//	@Summary		Start social login
+//	@Description	Redirects the browser to the OpenID Connect provider. After consent the provider calls back /authentication/oidc/{provider}/callback
+//	@Tags			authentication
+//	@Param			provider	path	string	true	"Provider name, e.g. google"
+//	@Success		302
+//	@Failure		404	{object}	swagger.EnvelopeError	"unknown provider"
+//	@Failure		500	{object}	swagger.EnvelopeError	"internal server error"
+//	@Router			/authentication/oidc/{provider} [get]
+func (app *application) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.oidcProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		app.notFoundResponse(w, r, errors.New("unknown login provider"))
+		return
+	}
+
+	state, err := auth.RandomString(32)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	nonce, err := auth.RandomString(32)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	verifier, err := auth.RandomString(32)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	hash := sha256.Sum256([]byte(state))
+	hashState := hex.EncodeToString(hash[:])
+
+	oidcState := &store.OIDCState{
+		Provider:     provider.Name(),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+	}
+
+	if err := app.store.Identities.CreateState(r.Context(), hashState, oidcState, app.config.auth.oidc.stateExp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+//	oidcCallbackHandler godoc
+//
+//	@Summary		Finish social login
+//	@Description	Validates state, nonce and the ID token, then creates or links the user. Redirects to FRONTEND_URL/oauth/callback with the tokens, a two-factor challenge_token or an error code in the URL fragment
+//	@Tags			authentication
+//	@Param			provider	path	string	true	"Provider name, e.g. google"
+//	@Param			state		query	string	true	"State from the login redirect"
+//	@Param			code		query	string	true	"Authorization code"
+//	@Success		302
+//	@Failure		404	{object}	swagger.EnvelopeError	"unknown provider"
+//	@Failure		500	{object}	swagger.EnvelopeError	"internal server error"
+//	@Router			/authentication/oidc/{provider}/callback [get]
+func (app *application) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.oidcProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		app.notFoundResponse(w, r, errors.New("unknown login provider"))
+		return
+	}
+
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		app.redirectOIDCResult(w, r, url.Values{"error": {providerErr}})
+		return
+	}
+
+	oidcState, err := app.store.Identities.ConsumeState(ctx, query.Get("state"))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.redirectOIDCResult(w, r, url.Values{"error": {oidcErrInvalidState}})
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if oidcState.Provider != provider.Name() {
+		app.redirectOIDCResult(w, r, url.Values{"error": {oidcErrInvalidState}})
+		return
+	}
+
+	claims, err := provider.Exchange(ctx, query.Get("code"), oidcState.CodeVerifier, oidcState.Nonce)
+	if err != nil {
+		app.logger.Warnw("oidc code exchange failed", "provider", provider.Name(), "error", err)
+		app.redirectOIDCResult(w, r, url.Values{"error": {oidcErrLoginFailed}})
+		return
+	}
+
+	user, err := app.userFromOIDC(ctx, provider.Name(), claims)
+	if err != nil {
+		var loginErr *oidcLoginError
+		switch {
+		case errors.As(err, &loginErr):
+			app.redirectOIDCResult(w, r, url.Values{"error": {loginErr.code}})
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := app.createTwoFactorChallenge(ctx, user, "")
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		app.redirectOIDCResult(w, r, url.Values{"challenge_token": {challenge.ChallengeToken}})
+		return
+	}
+
+	tokens, err := app.issueTokens(r, user, "")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.redirectOIDCResult(w, r, url.Values{
+		"token":         {tokens.Token},
+		"refresh_token": {tokens.RefreshToken},
+	})
+}
+
+// userFromOIDC returns the user behind a verified ID token. Known identities
+// log straight in, a verified email links to the matching account or
+// registers an active one, and an unverified email goes through the normal
+// activation email.
+func (app *application) userFromOIDC(ctx context.Context, provider string, claims *auth.OIDCClaims) (*store.User, error) {
+	identity, err := app.store.Identities.GetByProviderSubject(ctx, provider, claims.Subject)
+	switch err {
+	case nil:
+		user, err := app.store.Users.GetByID(ctx, identity.UserID)
+		if err == store.ErrNotFound {
+			return nil, &oidcLoginError{oidcErrAccountInactive}
+		}
+		return user, err
+	case store.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	if claims.Email == "" {
+		return nil, &oidcLoginError{oidcErrEmailNotVerified}
+	}
+
+	identity = &store.Identity{
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+
+	if claims.EmailVerified {
+		err := app.store.Identities.LinkByEmail(ctx, identity)
+		switch err {
+		case nil:
+			return app.store.Users.GetByID(ctx, identity.UserID)
+		case store.ErrNotFound:
+			return app.createOIDCUser(ctx, claims, identity, false)
+		case store.ErrIdentityAlreadyLinked:
+			return nil, &oidcLoginError{oidcErrAlreadyLinked}
+		default:
+			return nil, err
+		}
+	}
+
+	return app.createOIDCUser(ctx, claims, identity, true)
+}
+
+func (app *application) createOIDCUser(ctx context.Context, claims *auth.OIDCClaims, identity *store.Identity, invite bool) (*store.User, error) {
+	// the account is only reachable through the provider or a password reset
+	randomPassword, err := auth.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	var plainToken, hashToken string
+	if invite {
+		plainToken = uuid.New().String()
+		hash := sha256.Sum256([]byte(plainToken))
+		hashToken = hex.EncodeToString(hash[:])
+	}
+
+	base := oidcUsername(claims)
+
+	var user *store.User
+	for attempt := 0; ; attempt++ {
+		user = &store.User{
+			Username: base,
+			Email:    claims.Email,
+		}
+		if attempt > 0 {
+			user.Username = fmt.Sprintf("%s-%s", base, uuid.New().String()[:6])
+		}
+
+		if err := user.Password.Set(randomPassword); err != nil {
+			return nil, err
+		}
+
+		err = app.store.Identities.CreateWithUser(ctx, user, identity, hashToken, app.config.mail.exp)
+		if err != store.ErrDuplicateUsername || attempt == 3 {
+			break
+		}
+	}
+
+	switch err {
+	case nil:
+	case store.ErrDuplicateEmail:
+		// an existing account must not be taken over with an unverified email
+		return nil, &oidcLoginError{oidcErrEmailNotVerified}
+	default:
+		return nil, err
+	}
+
+	if !invite {
+		return user, nil
+	}
+
+	vars := struct {
+		Username      string
+		ActivationURL string
+	}{
+		Username:      user.Username,
+		ActivationURL: fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken),
+	}
+
+	if err := app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars); err != nil {
+		app.logger.Errorw("error sending welcome email", "error", err)
+
+		if err := app.store.Users.Delete(ctx, user.ID); err != nil {
+			app.logger.Errorw("error deleting user", "error", err)
+		}
+
+		return nil, err
+	}
+
+	return nil, &oidcLoginError{oidcErrActivationRequired}
+}
+
+func oidcUsername(claims *auth.OIDCClaims) string {
+	name := claims.PreferredUsername
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	name = usernameDisallowed.ReplaceAllString(name, "")
+	if len(name) > 50 {
+		name = name[:50]
+	}
+
+	if name == "" {
+		name = "reader"
+	}
+
+	return name
+}
+
+// redirectOIDCResult sends the browser back to the frontend. Values go in the
+// fragment so tokens never reach server logs or the Referer header.
+func (app *application) redirectOIDCResult(w http.ResponseWriter, r *http.Request, values url.Values) {
+	http.Redirect(w, r, app.config.frontendURL+"/oauth/callback#"+values.Encode(), http.StatusFound)
+}
+
+//	getIdentitiesHandler godoc
+//
+//	@Summary		List linked logins
+//	@Description	Social login providers linked to the current user
+//	@Tags			users
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		store.Identity
+//	@Failure		401	{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		500	{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/users/identities [get]
+func (app *application) getIdentitiesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	identities, err := app.store.Identities.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, identities); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+//	deleteIdentityHandler godoc
+//
+//	@Summary		Unlink a login provider
+//	@Description	Unlink a social login provider from the current user
+//	@Tags			users
+//	@Security		BearerAuth
+//	@Param			provider	path	string	true	"Provider name"
+//	@Success		204			{}		"Provider unlinked"
+//	@Failure		401			{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		404			{object}	swagger.EnvelopeError	"Not found"
+//	@Failure		500			{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/users/identities/{provider} [delete]
+func (app *application) deleteIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if err := app.store.Identities.Delete(r.Context(), user.ID, chi.URLParam(r, "provider")); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
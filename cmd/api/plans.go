@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AlfanDutaPamungkas/Govel/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type planKey string
+
+const planCtx planKey = "plan"
+
+type CoinPlanPayload struct {
+	Code         string     `json:"code" validate:"required,max=20,alphanum,lowercase"`
+	Name         string     `json:"name" validate:"required,max=100"`
+	Description  string     `json:"description" validate:"max=255"`
+	Price        float64    `json:"price" validate:"required,gt=0"`
+	Currency     string     `json:"currency" validate:"required,iso4217"`
+	Coins        int64      `json:"coins" validate:"required,min=1"`
+	BonusCoins   int64      `json:"bonus_coins" validate:"min=0"`
+	ActiveFrom   *time.Time `json:"active_from"`
+	ActiveUntil  *time.Time `json:"active_until"`
+	DisplayOrder int        `json:"display_order"`
+}
+
+func (p CoinPlanPayload) validate() error {
+	if err := Validate.Struct(p); err != nil {
+		return err
+	}
+
+	if p.ActiveFrom != nil && p.ActiveUntil != nil && !p.ActiveUntil.After(*p.ActiveFrom) {
+		return errors.New("active_until must be after active_from")
+	}
+
+	return nil
+}
+
+func (p CoinPlanPayload) apply(plan *store.CoinPlan) {
+	plan.Code = p.Code
+	plan.Name = p.Name
+	plan.Description = p.Description
+	plan.Price = p.Price
+	plan.Currency = p.Currency
+	plan.Coins = p.Coins
+	plan.BonusCoins = p.BonusCoins
+	plan.ActiveFrom = p.ActiveFrom
+	plan.ActiveUntil = p.ActiveUntil
+	plan.DisplayOrder = p.DisplayOrder
+}
+
+// getPlansHandler godoc
+//
+//	@Summary		Get coin plans
+//	@Description	The coin plans on sale now, in display order
+//	@Tags			plans
+//	@Produce		json
+//	@Success		200	{array}		store.CoinPlan
+//	@Failure		500	{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/plans [get]
+func (app *application) getPlansHandler(w http.ResponseWriter, r *http.Request) {
+	plans, err := app.store.CoinPlans.GetActive(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, plans); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// getAllPlansHandler godoc
+//
+//	@Summary		Get all coin plans
+//	@Description	Every coin plan, including those not on sale, in display order. Requires plans:manage
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		store.CoinPlan
+//	@Failure		401	{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		403	{object}	swagger.EnvelopeError	"Forbidden"
+//	@Failure		500	{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/admin/plans [get]
+func (app *application) getAllPlansHandler(w http.ResponseWriter, r *http.Request) {
+	plans, err := app.store.CoinPlans.GetAll(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, plans); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// createPlanHandler godoc
+//
+//	@Summary		Create coin plan
+//	@Description	Create a coin plan. It is on sale between active_from and active_until, both optional. Requires plans:manage
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			payload	body		CoinPlanPayload	true	"Plan"
+//	@Success		201		{object}	store.CoinPlan
+//	@Failure		400		{object}	swagger.EnvelopeError	"Invalid request or plan code already exists"
+//	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		403		{object}	swagger.EnvelopeError	"Forbidden"
+//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/admin/plans [post]
+func (app *application) createPlanHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CoinPlanPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := payload.validate(); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	plan := &store.CoinPlan{}
+	payload.apply(plan)
+
+	if err := app.store.CoinPlans.Create(r.Context(), plan); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicatePlanCode):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, plan); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// updatePlanHandler godoc
+//
+//	@Summary		Update coin plan
+//	@Description	Replace a coin plan. Invoices already created keep the price and coins they were made with. Requires plans:manage
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			planID	path		int				true	"Plan ID"
+//	@Param			payload	body		CoinPlanPayload	true	"Plan"
+//	@Success		200		{object}	store.CoinPlan
+//	@Failure		400		{object}	swagger.EnvelopeError	"Invalid request or plan code already exists"
+//	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		403		{object}	swagger.EnvelopeError	"Forbidden"
+//	@Failure		404		{object}	swagger.EnvelopeError	"Plan not found"
+//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/admin/plans/{planID} [put]
+func (app *application) updatePlanHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CoinPlanPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := payload.validate(); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	plan := getPlanFromCtx(r)
+	payload.apply(plan)
+
+	if err := app.store.CoinPlans.Update(r.Context(), plan); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrDuplicatePlanCode):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, plan); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// deletePlanHandler godoc
+//
+//	@Summary		Delete coin plan
+//	@Description	Delete a coin plan. Invoices made with it are kept. Requires plans:manage
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Param			planID	path	int	true	"Plan ID"
+//	@Success		204		{}		"Plan deleted"
+//	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		403		{object}	swagger.EnvelopeError	"Forbidden"
+//	@Failure		404		{object}	swagger.EnvelopeError	"Plan not found"
+//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/admin/plans/{planID} [delete]
+func (app *application) deletePlanHandler(w http.ResponseWriter, r *http.Request) {
+	plan := getPlanFromCtx(r)
+
+	if err := app.store.CoinPlans.Delete(r.Context(), plan.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) plansContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "planID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		plan, err := app.store.CoinPlans.GetByID(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, planCtx, plan)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getPlanFromCtx(r *http.Request) *store.CoinPlan {
+	plan, _ := r.Context().Value(planCtx).(*store.CoinPlan)
+	return plan
+}
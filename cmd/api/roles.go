@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/AlfanDutaPamungkas/Govel/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+//	getRolesHandler godoc
+//
+//	@Summary		List roles
+//	@Description	List every role with the permissions it grants. Requires roles:manage
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		store.Role
+//	@Failure		401	{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		403	{object}	swagger.EnvelopeError	"Forbidden"
+//	@Failure		500	{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/admin/roles [get]
+func (app *application) getRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := app.store.Roles.GetAll(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, roles); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type AssignRolePayload struct {
+	Role string `json:"role" validate:"required,max=20"`
+}
+
+//	assignRoleHandler godoc
+//
+//	@Summary		Assign role
+//	@Description	Change the role of a user. Requires roles:manage
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			userID	path	int					true	"User ID"
+//	@Param			payload	body	AssignRolePayload	true	"Role name"
+//	@Success		204		{}			"Role assigned"
+//	@Failure		400		{object}	swagger.EnvelopeError	"Invalid request"
+//	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		403		{object}	swagger.EnvelopeError	"Forbidden"
+//	@Failure		404		{object}	swagger.EnvelopeError	"User or role not found"
+//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/admin/users/{userID}/role [put]
+func (app *application) assignRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload AssignRolePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if userID == getUserFromCtx(r).ID {
+		app.badRequestResponse(w, r, errors.New("you cannot change your own role"))
+		return
+	}
+
+	if err := app.store.Roles.AssignToUser(r.Context(), userID, payload.Role); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AlfanDutaPamungkas/Govel/internal/mailer"
+	"github.com/AlfanDutaPamungkas/Govel/internal/store"
+)
+
+// releaseNoticeQueueSize is how many released chapters may wait for their
+// emails before the release step waits for the mail workers.
+const releaseNoticeQueueSize = 256
+
+// chapterNotice is a released chapter whose readers are still to be emailed.
+type chapterNotice struct {
+	novel   *store.Novel
+	chapter *store.Chapter
+}
+
+// releaseMail is one chapter release email.
+type releaseMail struct {
+	user    *store.User
+	chapter string
+	vars    chapterReleasedVars
+}
+
+type chapterReleasedVars struct {
+	Username     string
+	NovelTitle   string
+	ChapterTitle string
+	ChapterURL   string
+}
+
+// releaseChapters periodically publishes the novels and chapters whose
+// scheduled time has come and queues emails to the readers who bookmarked
+// their novels.
+func (app *application) releaseChapters(ctx context.Context) {
+	ticker := time.NewTicker(app.config.chapter.releaseInterval)
+	defer ticker.Stop()
+
+	for {
+		app.releaseChaptersOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *application) releaseChaptersOnce(ctx context.Context) {
+	// Novels go first, so readers are told about chapters of novels that went
+	// live in the same tick
+	published, err := app.store.Novels.PublishScheduled(ctx)
+	if err != nil {
+		app.logger.Errorw("error publishing scheduled novels", "error", err)
+	} else if published > 0 {
+		app.logger.Infow("published scheduled novels", "novels", published)
+	}
+
+	chapters, err := app.store.Chapters.ReleaseDue(ctx)
+	if err != nil {
+		app.logger.Errorw("error releasing scheduled chapters", "error", err)
+		return
+	}
+
+	if len(chapters) == 0 {
+		return
+	}
+
+	app.logger.Infow("released scheduled chapters", "chapters", len(chapters))
+
+	for _, chapter := range chapters {
+		novel, err := app.store.Novels.GetByID(ctx, chapter.NovelID, 0)
+		if err != nil {
+			app.logger.Errorw("error getting novel of released chapter", "chapter", chapter.Slug, "error", err)
+			continue
+		}
+
+		app.queueChapterReleased(ctx, novel, chapter)
+	}
+}
+
+// queueChapterReleased queues the emails about chapter for sendReleaseEmails.
+func (app *application) queueChapterReleased(ctx context.Context, novel *store.Novel, chapter *store.Chapter) {
+	select {
+	case app.releaseNotices <- chapterNotice{novel: novel, chapter: chapter}:
+	case <-ctx.Done():
+	}
+}
+
+// sendReleaseEmails emails the readers of the chapters queued by the release
+// step, using a fixed number of workers so a popular novel neither holds up
+// releases nor opens a connection per reader at once.
+func (app *application) sendReleaseEmails(ctx context.Context) {
+	mails := make(chan releaseMail)
+
+	var wg sync.WaitGroup
+	for i := 0; i < app.config.chapter.mailWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for mail := range mails {
+				if err := app.mailer.Send(mailer.ChapterReleasedTemplate, mail.user.Username, mail.user.Email, mail.vars); err != nil {
+					app.logger.Errorw("error sending chapter released email", "user_id", mail.user.ID, "chapter", mail.chapter, "error", err)
+				}
+			}
+		}()
+	}
+
+	defer func() {
+		close(mails)
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notice := <-app.releaseNotices:
+			app.notifyChapterReleased(ctx, notice.novel, notice.chapter, mails)
+		}
+	}
+}
+
+// notifyChapterReleased hands mails an email about chapter for every reader who
+// bookmarked novel. Nobody is told about chapters of novels readers can't see
+// yet.
+func (app *application) notifyChapterReleased(ctx context.Context, novel *store.Novel, chapter *store.Chapter, mails chan<- releaseMail) {
+	if !novel.IsPublic() {
+		return
+	}
+
+	subscribers, err := app.store.Bookmarks.GetSubscribers(ctx, novel.ID)
+	if err != nil {
+		app.logger.Errorw("error getting novel subscribers", "novel_id", novel.ID, "error", err)
+		return
+	}
+
+	vars := chapterReleasedVars{
+		NovelTitle:   novel.Title,
+		ChapterTitle: chapter.Title,
+		ChapterURL:   fmt.Sprintf("%s/novel/%d/chapter/%s", app.config.frontendURL, novel.ID, chapter.Slug),
+	}
+
+	for _, user := range subscribers {
+		vars.Username = user.Username
+
+		select {
+		case mails <- releaseMail{user: user, chapter: chapter.Slug, vars: vars}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
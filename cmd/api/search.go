@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/AlfanDutaPamungkas/Govel/internal/cache"
+	"github.com/AlfanDutaPamungkas/Govel/internal/store"
+)
+
+const (
+	suggestMinLength = 2
+	suggestMaxLength = 64
+	suggestLimit     = 5
+)
+
+//	suggestHandler godoc
+//
+//	@Summary		Search suggestions
+//	@Description	Autocomplete for the search box. Returns up to 5 novel titles, authors and genres each matching q, prefix matches first. Queries shorter than 2 characters return nothing
+//	@Tags			search
+//	@Produce		json
+//	@Param			q	query		string	true	"What the user has typed so far"
+//	@Success		200	{array}		store.Suggestion
+//	@Failure		500	{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/search/suggest [get]
+func (app *application) suggestHandler(w http.ResponseWriter, r *http.Request) {
+	q := normalizeSuggestQuery(r.URL.Query().Get("q"))
+
+	if utf8.RuneCountInString(q) < suggestMinLength {
+		if err := app.jsonResponse(w, http.StatusOK, []*store.Suggestion{}); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	suggestions, ok := app.suggestions.Get(q)
+	if !ok {
+		var err error
+		suggestions, err = app.store.Search.Suggest(r.Context(), q, suggestLimit)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		app.suggestions.Add(q, suggestions)
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, suggestions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func newSuggestionCache(cfg searchConfig) *cache.LRU[string, []*store.Suggestion] {
+	return cache.NewLRU[string, []*store.Suggestion](cfg.suggestCacheSize, cfg.suggestCacheTTL)
+}
+
+// normalizeSuggestQuery lowercases q, collapses whitespace and truncates it
+// so that equivalent prefixes share a cache entry.
+func normalizeSuggestQuery(q string) string {
+	q = strings.Join(strings.Fields(strings.ToLower(q)), " ")
+
+	if utf8.RuneCountInString(q) > suggestMaxLength {
+		q = string([]rune(q)[:suggestMaxLength])
+	}
+
+	return q
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/AlfanDutaPamungkas/Govel/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type sessionKey string
+
+const sessionCtx sessionKey = "session"
+
+type peerKey string
+
+const peerCtx peerKey = "peer"
+
+//	getSessionsHandler godoc
+//
+//	@Summary		Get sessions
+//	@Description	List the devices the current user is signed in on
+//	@Tags			users
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		store.Session			"Active sessions"
+//	@Failure		401	{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		500	{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/users/sessions [get]
+func (app *application) getSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	current := getSessionFromCtx(r)
+
+	sessions, err := app.store.Sessions.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == current.ID
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, sessions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+//	deleteSessionHandler godoc
+//
+//	@Summary		Delete session
+//	@Description	Sign out a single device of the current user
+//	@Tags			users
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			sessionID	path	int	true	"Session ID"
+//	@Success		204			{}			"Session revoked"
+//	@Failure		401			{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		404			{object}	swagger.EnvelopeError	"Session not found"
+//	@Failure		500			{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/users/sessions/{sessionID} [delete]
+func (app *application) deleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Sessions.Revoke(r.Context(), user.ID, sessionID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//	getUserSessionsHandler godoc
+//
+//	@Summary		Get user sessions
+//	@Description	List the devices a user is signed in on. Requires users:manage
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			userID	path		int						true	"User ID"
+//	@Success		200		{array}		store.Session			"Active sessions"
+//	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		403		{object}	swagger.EnvelopeError	"Forbidden"
+//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/admin/users/{userID}/sessions [get]
+func (app *application) getUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	sessions, err := app.store.Sessions.GetByUserID(r.Context(), userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, sessions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+//	deleteUserSessionsHandler godoc
+//
+//	@Summary		Force logout user
+//	@Description	Revoke every session and token of a user. Requires users:manage
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204		{}			"Sessions revoked"
+//	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		403		{object}	swagger.EnvelopeError	"Forbidden"
+//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/admin/users/{userID}/sessions [delete]
+func (app *application) deleteUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Sessions.RevokeAll(r.Context(), userID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getSessionFromCtx(r *http.Request) *store.Session {
+	session, _ := r.Context().Value(sessionCtx).(*store.Session)
+	return session
+}
+
+// clientIP returns the address set by middleware.RealIP without the port. It
+// comes from headers any client can set, so it is only fit for display.
+func clientIP(r *http.Request) string {
+	return hostOf(r.RemoteAddr)
+}
+
+// limiterIP returns the address rate limits are keyed by: the connection's
+// own, unless it comes from a trusted proxy that forwarded the client's.
+func (app *application) limiterIP(r *http.Request) string {
+	peer, ok := r.Context().Value(peerCtx).(string)
+	if !ok {
+		return clientIP(r)
+	}
+
+	host := hostOf(peer)
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+
+	for _, proxy := range app.proxies {
+		if proxy.Contains(addr.Unmap()) {
+			return clientIP(r)
+		}
+	}
+
+	return host
+}
+
+// parseProxies parses a comma separated list of IPs and CIDRs.
+func parseProxies(list string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+			}
+
+			addr = addr.Unmap()
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+
+	return proxies, nil
+}
+
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	return host
+}
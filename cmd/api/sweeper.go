@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// sweep periodically purges expired invitations, the accounts that were
+// never activated within the grace period and the accounts whose deletion
+// grace period is over and checks the coin balances against the ledger.
+func (app *application) sweep(ctx context.Context) {
+	ticker := time.NewTicker(app.config.activation.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		app.sweepOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepOnce runs every sweep task. A failing task is logged and doesn't keep
+// the others from running.
+func (app *application) sweepOnce(ctx context.Context) {
+	app.sweepUnactivated(ctx)
+	app.purgeDeleted(ctx)
+	app.reconcileCoins(ctx)
+}
+
+func (app *application) sweepUnactivated(ctx context.Context) {
+	users, err := app.store.Users.DeleteUnactivated(ctx, app.config.activation.gracePeriod)
+	if err != nil {
+		app.logger.Errorw("error deleting unactivated users", "error", err)
+	}
+
+	invitations, err := app.store.Users.DeleteExpiredInvitations(ctx)
+	if err != nil {
+		app.logger.Errorw("error deleting expired invitations", "error", err)
+	}
+
+	if users > 0 || invitations > 0 {
+		app.logger.Infow("swept unactivated accounts", "users", users, "invitations", invitations)
+	}
+}
+
+func (app *application) purgeDeleted(ctx context.Context) {
+	deleted, err := app.store.Users.PurgeDeleted(ctx, app.config.deletion.gracePeriod)
+	if err != nil {
+		app.logger.Errorw("error purging deleted users", "error", err)
+		return
+	}
+
+	if deleted > 0 {
+		app.logger.Infow("purged deleted accounts", "users", deleted)
+	}
+}
+
+func (app *application) reconcileCoins(ctx context.Context) {
+	mismatches, err := app.store.Coins.Reconcile(ctx)
+	if err != nil {
+		app.logger.Errorw("error reconciling coin balances", "error", err)
+		return
+	}
+
+	for _, mismatch := range mismatches {
+		app.logger.Errorw("coin balance does not match the ledger",
+			"user_id", mismatch.UserID,
+			"balance", mismatch.Balance,
+			"ledger_balance", mismatch.LedgerBalance,
+		)
+	}
+}
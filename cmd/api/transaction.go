@@ -2,80 +2,95 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 
+	"github.com/AlfanDutaPamungkas/Govel/internal/payments"
 	"github.com/AlfanDutaPamungkas/Govel/internal/store"
 )
 
-type XenditWebhookPayload struct {
-	InvoiceID  string `json:"id"`           // ID dari Xendit
-	ExternalID string `json:"external_id"`  // UUID invoice yang kamu generate
-	Status     string `json:"status"`       // PAID / EXPIRED / lainnya
-}
+const webhookMaxBytes = 1_048_578
 
 type response struct {
 	Status string `json:"status"`
 	Coin   int64  `json:"coin"`
 }
 
+//	transactionHandler godoc
+//
+//	@Summary		Payment provider invoice callback
+//	@Description	Called by the payment provider when an invoice changes status. For Xendit the x-callback-token header must match XENDIT_CALLBACK_TOKEN. Every delivery is recorded and redeliveries of a processed event are acknowledged without effect. Coins are credited the first time an invoice is paid
+//	@Tags			invoices
+//	@Accept			json
+//	@Produce		json
+//	@Param			x-callback-token	header		string						true	"Callback verification token"
+//	@Param			payload				body		payments.InvoiceCallback	true	"Invoice callback"
+//	@Success		200					{object}	response
+//	@Failure		400					{object}	swagger.EnvelopeError	"Invalid payload or status"
+//	@Failure		401					{object}	swagger.EnvelopeError	"Invalid callback token"
+//	@Failure		404					{object}	swagger.EnvelopeError	"Invoice not found"
+//	@Failure		500					{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/webhook [post]
 func (app *application) transactionHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	var payload XenditWebhookPayload
-	if err := readJSON(w, r, &payload); err != nil {
-		app.badRequestResponse(w, r, err)
-		return
-	}
+	r.Body = http.MaxBytesReader(w, r.Body, webhookMaxBytes)
 
-	invoice, err := app.store.Invoices.GetByInvoiceID(ctx, payload.InvoiceID)
+	callback, err := app.payments.ParseWebhook(r)
 	if err != nil {
-		if errors.Is(err, store.ErrNotFound) {
-			app.notFoundResponse(w, r, err)
-		} else {
-			app.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, payments.ErrInvalidSignature):
+			app.unauthorizedResponse(w, r, err)
+		default:
+			app.badRequestResponse(w, r, err)
 		}
 		return
 	}
 
-	if invoice.Status == "PAID" {
-		app.badRequestResponse(w, r, errors.New("invoice already paid"))
+	if !slices.Contains(store.InvoiceStatuses, callback.Status) {
+		app.badRequestResponse(w, r, fmt.Errorf("unknown invoice status %q", callback.Status))
 		return
 	}
 
-	user, err := app.store.Users.GetByID(ctx, invoice.UserID)
-	if err != nil {
-		app.internalServerError(w, r, err)
-		return
+	event := &store.WebhookEvent{
+		Provider:  app.payments.Name(),
+		EventID:   callback.ID,
+		InvoiceID: callback.InvoiceID,
+		Status:    callback.Status,
+		Payload:   callback.Payload,
 	}
 
-	planCoin := map[string]int{
-		"lite":   120,
-		"scroll": 700,
-		"volume": 1300,
-	}
-
-	coin, ok := planCoin[invoice.Plan]
-	if !ok {
-		app.badRequestResponse(w, r, errors.New("plan not found"))
-		return
-	}
-
-	if payload.Status != "PAID" {
-		app.badRequestResponse(w, r, errors.New("payment failed or not completed"))
+	if err := app.store.WebhookEvents.Record(ctx, event); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateWebhook):
+			if err := app.jsonResponse(w, http.StatusOK, response{Status: callback.Status}); err != nil {
+				app.internalServerError(w, r, err)
+			}
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
-	user.Coin = int64(coin)
-	invoice.Status = payload.Status
+	coins, err := app.processInvoiceEvent(r, event)
+	if err != nil {
+		if failErr := app.store.WebhookEvents.Fail(ctx, event.ID, err); failErr != nil {
+			app.logger.Errorw("error recording webhook failure", "event_id", event.ID, "error", failErr)
+		}
 
-	if err := app.store.Users.Webhook(ctx, user, invoice); err != nil {
-		app.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
 	resp := response{
-		Status: payload.Status,
-		Coin:   user.Coin,
+		Status: callback.Status,
+		Coin:   coins,
 	}
 
 	if err := app.jsonResponse(w, http.StatusOK, resp); err != nil {
@@ -83,3 +98,18 @@ func (app *application) transactionHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 }
+
+// processInvoiceEvent applies the status of event to its invoice and returns
+// the coins credited to the buyer, as snapshotted on the invoice.
+func (app *application) processInvoiceEvent(r *http.Request, event *store.WebhookEvent) (int64, error) {
+	ctx := r.Context()
+
+	invoice, err := app.store.Invoices.GetByInvoiceID(ctx, event.InvoiceID)
+	if err != nil {
+		return 0, err
+	}
+
+	invoice.Status = event.Status
+
+	return app.store.Users.Webhook(ctx, event, invoice)
+}
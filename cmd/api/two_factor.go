@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/AlfanDutaPamungkas/Govel/internal/auth"
+	"github.com/AlfanDutaPamungkas/Govel/internal/store"
+	"github.com/google/uuid"
+)
+
+const recoveryCodeCount = 10
+
+var errInvalidSecondFactor = errors.New("invalid two-factor code")
+
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+//	setupTwoFactorHandler godoc
+//
+//	@Summary		Start two-factor setup
+//	@Description	Generate a TOTP secret and the otpauth:// URI to show as a QR code
+//	@Tags			users
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		201	{object}	TwoFactorSetupResponse	"TOTP secret"
+//	@Failure		400	{object}	swagger.EnvelopeError	"Already enabled"
+//	@Failure		401	{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		500	{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/users/2fa/setup [post]
+func (app *application) setupTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if user.TOTPEnabled {
+		app.badRequestResponse(w, r, errors.New("two-factor authentication is already enabled"))
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.TwoFactor.SetSecret(r.Context(), user.ID, secret); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	resp := TwoFactorSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(app.config.auth.twoFactor.issuer, user.Email, secret),
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, resp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type EnableTwoFactorPayload struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+//	enableTwoFactorHandler godoc
+//
+//	@Summary		Enable two-factor authentication
+//	@Description	Confirm the secret from setup with a code and receive single-use recovery codes
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			payload	body		EnableTwoFactorPayload	true	"TOTP code"
+//	@Success		200		{object}	RecoveryCodesResponse	"Recovery codes"
+//	@Failure		400		{object}	swagger.EnvelopeError	"Invalid code"
+//	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/users/2fa/enable [post]
+func (app *application) enableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var payload EnableTwoFactorPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if user.TOTPEnabled {
+		app.badRequestResponse(w, r, errors.New("two-factor authentication is already enabled"))
+		return
+	}
+
+	if user.TOTPSecret == "" {
+		app.badRequestResponse(w, r, errors.New("start two-factor setup first"))
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(user.TOTPSecret, payload.Code, time.Now())
+	if !ok {
+		app.badRequestResponse(w, r, errInvalidSecondFactor)
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	hashCodes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hash := sha256.Sum256([]byte(code))
+		hashCodes = append(hashCodes, hex.EncodeToString(hash[:]))
+	}
+
+	if err := app.store.TwoFactor.Enable(r.Context(), user.ID, step, hashCodes); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type DisableTwoFactorPayload struct {
+	Password     string `json:"password" validate:"required,max=72"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code,omitempty,max=11"`
+}
+
+//	disableTwoFactorHandler godoc
+//
+//	@Summary		Disable two-factor authentication
+//	@Description	Turn off two-factor authentication with the password and a code
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			payload	body	DisableTwoFactorPayload	true	"Password and code"
+//	@Success		204		{}			"Two-factor authentication disabled"
+//	@Failure		400		{object}	swagger.EnvelopeError	"Invalid password or code"
+//	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/users/2fa [delete]
+func (app *application) disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var payload DisableTwoFactorPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !user.TOTPEnabled {
+		app.badRequestResponse(w, r, errors.New("two-factor authentication is not enabled"))
+		return
+	}
+
+	if user.IsStaff() && app.config.auth.twoFactor.requiredForAdmins {
+		app.badRequestResponse(w, r, errors.New("two-factor authentication is mandatory for staff accounts"))
+		return
+	}
+
+	if !user.Password.Verify(payload.Password) {
+		app.badRequestResponse(w, r, errors.New("password is incorrect"))
+		return
+	}
+
+	if err := app.verifySecondFactor(r.Context(), user, payload.Code, payload.RecoveryCode); err != nil {
+		switch {
+		case errors.Is(err, errInvalidSecondFactor):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.TwoFactor.Disable(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type VerifyTwoFactorPayload struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode   string `json:"recovery_code" validate:"required_without=Code,omitempty,max=11"`
+}
+
+//	verifyTwoFactorHandler godoc
+//
+//	@Summary		Complete two-factor login
+//	@Description	Exchange the challenge returned by /authentication/token and a TOTP or recovery code for tokens
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@param			payload	body		VerifyTwoFactorPayload	true	"Challenge and code"
+//	@Success		201		{object}	TokenResponse			"Access and refresh token"
+//	@Failure		400		{object}	swagger.EnvelopeError	"invalid request"
+//	@Failure		401		{object}	swagger.EnvelopeError	"invalid challenge or code"
+//	@Failure		429		{object}	swagger.EnvelopeError	"too many failed attempts"
+//	@Failure		500		{object}	swagger.EnvelopeError	"internal server error"
+//	@Router			/authentication/2fa [post]
+func (app *application) verifyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload VerifyTwoFactorPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	challenge, err := app.store.TwoFactor.GetChallenge(ctx, payload.ChallengeToken)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedResponse(w, r, errors.New("invalid or expired challenge"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unauthorizedResponse(w, r, errors.New("invalid or expired challenge"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		app.rateLimitExceededResponse(w, r, time.Until(*user.LockedUntil))
+		return
+	}
+
+	if err := app.verifySecondFactor(ctx, user, payload.Code, payload.RecoveryCode); err != nil {
+		switch {
+		case errors.Is(err, errInvalidSecondFactor):
+			app.recordFailedLogin(r, user)
+			app.unauthorizedResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.TwoFactor.DeleteChallenge(ctx, payload.ChallengeToken); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if user.FailedLoginAttempts > 0 {
+		if err := app.store.Users.ResetFailedLogins(ctx, user.ID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	tokens, err := app.issueTokens(r, user, challenge.Device)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// createTwoFactorChallenge is used by createTokenHandler instead of issuing
+// tokens when the password was right but a second factor is still needed.
+func (app *application) createTwoFactorChallenge(ctx context.Context, user *store.User, device string) (*TwoFactorChallengeResponse, error) {
+	plainToken := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	challenge := &store.TwoFactorChallenge{
+		UserID: user.ID,
+		Device: device,
+	}
+
+	if err := app.store.TwoFactor.CreateChallenge(ctx, hashToken, challenge, app.config.auth.twoFactor.challengeExp); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    plainToken,
+	}, nil
+}
+
+// verifySecondFactor accepts either a TOTP code, which can only be used once,
+// or an unused recovery code.
+func (app *application) verifySecondFactor(ctx context.Context, user *store.User, code, recoveryCode string) error {
+	if code != "" {
+		step, ok := auth.ValidateTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return errInvalidSecondFactor
+		}
+
+		if err := app.store.TwoFactor.UseStep(ctx, user.ID, step); err != nil {
+			if errors.Is(err, store.ErrTOTPCodeReused) {
+				return fmt.Errorf("%w: %w", errInvalidSecondFactor, err)
+			}
+			return err
+		}
+
+		return nil
+	}
+
+	if err := app.store.TwoFactor.UseRecoveryCode(ctx, user.ID, recoveryCode); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return errInvalidSecondFactor
+		}
+		return err
+	}
+
+	return nil
+}
@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	cld "github.com/AlfanDutaPamungkas/Govel/internal/cloudinary"
+	"github.com/AlfanDutaPamungkas/Govel/internal/mailer"
 	"github.com/AlfanDutaPamungkas/Govel/internal/store"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 )
 
 type userKey string
@@ -80,6 +87,11 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if user.DeletedAt != nil {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -107,6 +119,11 @@ func (app *application) getProfileHandler(w http.ResponseWriter, r *http.Request
 	}
 }
 
+type UpdateUserResponse struct {
+	*store.User
+	PendingEmail string `json:"pending_email,omitempty"`
+}
+
 type CreateUpdateUsernamePayload struct {
 	Username string `json:"username" validate:"max=255"`
 	Email    string `json:"email" validate:"omitempty,email,max=255"`
@@ -115,13 +132,13 @@ type CreateUpdateUsernamePayload struct {
 //	updateUserHandler godoc
 //
 //	@Summary		Update user profile
-//	@Description	Update user profile, including username and/or email
+//	@Description	Update user profile, including username and/or email. A new email is only applied once it is confirmed through the link sent to it
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body	CreateUpdateUsernamePayload	true	"Updated user profile data"
 //	@Security		BearerAuth
-//	@Success		200	{object}	store.User				"Updated user profile data"
+//	@Success		200	{object}	UpdateUserResponse		"Updated user profile data"
 //	@Failure		400	{object}	swagger.EnvelopeError	"Bad request"
 //	@Failure		401	{object}	swagger.EnvelopeError	"Unauthorized"
 //	@Failure		404	{object}	swagger.EnvelopeError	"User not found"
@@ -146,42 +163,140 @@ func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	resp := UpdateUserResponse{User: user}
+
+	if payload.Email != "" && !strings.EqualFold(payload.Email, user.Email) {
+		err := app.requestEmailChange(r.Context(), user, payload.Email)
+		if err != nil {
+			switch err {
+			case store.ErrDuplicateEmail:
+				app.badRequestResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		resp.PendingEmail = payload.Email
+	}
+
 	if payload.Username != "" {
 		user.Username = payload.Username
+		user.UpdatedAt = time.Now()
+
+		if err := app.store.Users.Update(r.Context(), user); err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
 	}
 
-	if payload.Email != "" {
-		user.Email = payload.Email
-		user.TokenVersion++
+	if err := app.jsonResponse(w, http.StatusOK, resp); err != nil {
+		app.internalServerError(w, r, err)
+		return
 	}
+}
 
-	user.UpdatedAt = time.Now()
+// requestEmailChange stores the new address as pending and mails a
+// confirmation link to it, plus a heads-up to the address in use now.
+func (app *application) requestEmailChange(ctx context.Context, user *store.User, newEmail string) error {
+	_, err := app.store.Users.GetByEmail(ctx, newEmail)
+	switch err {
+	case nil:
+		return store.ErrDuplicateEmail
+	case store.ErrNotFound:
+	default:
+		return err
+	}
 
-	if err := app.store.Users.Update(r.Context(), user); err != nil {
-		switch {
-		case errors.Is(err, store.ErrNotFound):
+	plainToken := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	if err := app.store.Users.CreateEmailChangeReq(ctx, hashToken, user.ID, newEmail, app.config.mail.exp); err != nil {
+		return err
+	}
+
+	confirmVars := struct {
+		Username   string
+		ConfirmURL string
+	}{
+		Username:   user.Username,
+		ConfirmURL: fmt.Sprintf("%s/confirm-email/%s", app.config.frontendURL, plainToken),
+	}
+
+	if err := app.mailer.Send(mailer.EmailChangeConfirmTemplate, user.Username, newEmail, confirmVars); err != nil {
+		app.logger.Errorw("error sending email change confirmation", "error", err)
+
+		if err := app.store.Users.DeleteEmailChangeReq(ctx, user.ID); err != nil {
+			app.logger.Errorw("error deleting email change request", "error", err)
+		}
+
+		return err
+	}
+
+	noticeVars := struct {
+		Username          string
+		NewEmail          string
+		ForgotPasswordURL string
+	}{
+		Username:          user.Username,
+		NewEmail:          newEmail,
+		ForgotPasswordURL: fmt.Sprintf("%s/forgot-password", app.config.frontendURL),
+	}
+
+	// the change can still be confirmed if the old address is unreachable
+	if err := app.mailer.Send(mailer.EmailChangeNoticeTemplate, user.Username, user.Email, noticeVars); err != nil {
+		app.logger.Errorw("error sending email change notice", "error", err)
+	}
+
+	return nil
+}
+
+//	confirmEmailChangeHandler godoc
+//
+//	@Summary		Confirm email change
+//	@Description	Apply a pending email change using the token sent to the new address. Signs out every session of the user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			token	path		string	true	"Email change token"
+//	@Success		204		{}			"Email changed"
+//	@Failure		400		{object}	swagger.EnvelopeError	"email already taken"
+//	@Failure		404		{object}	swagger.EnvelopeError	"invalid token"
+//	@Failure		500		{object}	swagger.EnvelopeError	"internal server error"
+//	@Router			/users/email/{token} [put]
+func (app *application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	err := app.store.Users.ConfirmEmailChange(r.Context(), token)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
 			app.notFoundResponse(w, r, err)
+		case store.ErrDuplicateEmail:
+			app.badRequestResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 type ChangePasswordPayload struct {
-	OldPassword string `json:"old_password" validate:"required,min=8,max=72"`
-	NewPassword string `json:"new_password" validate:"required,min=8,max=72"`
+	OldPassword string `json:"old_password" validate:"required,max=72"`
+	NewPassword string `json:"new_password" validate:"required,max=72"`
 }
 
 //	changePasswordHandler godoc
 //
 //	@Summary		Change user password
-//	@Description	Change the password of the currently authenticated user
+//	@Description	Change the password of the currently authenticated user and sign out every session
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
@@ -216,6 +331,11 @@ func (app *application) changePasswordHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if err := app.passwords.Validate(payload.NewPassword, user.Username, user.Email); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	if err := user.Password.Set(payload.NewPassword); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -234,6 +354,11 @@ func (app *application) changePasswordHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if err := app.store.Sessions.RevokeAll(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, "password changed succesfully"); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -293,7 +418,70 @@ func (app *application) changeUserImageHandler(w http.ResponseWriter, r *http.Re
 	}
 }
 
+//	unlockUserHandler godoc
+//
+//	@Summary		Unlock user
+//	@Description	Clear failed login attempts and lift a temporary lockout. Requires users:manage
+//	@Tags			admin
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204		{}			"User unlocked"
+//	@Failure		400		{object}	swagger.EnvelopeError	"Invalid user ID"
+//	@Failure		401		{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		403		{object}	swagger.EnvelopeError	"Forbidden"
+//	@Failure		404		{object}	swagger.EnvelopeError	"User not found"
+//	@Failure		500		{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/admin/users/{userID}/unlock [patch]
+func (app *application) unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.Unlock(r.Context(), userID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func getUserFromCtx(r *http.Request) *store.User {
 	user, _ := r.Context().Value(userCtx).(*store.User)
 	return user
 }
+
+//	becomeAuthorHandler godoc
+//
+//	@Summary		Become an author
+//	@Description	Turn a reader account into an author account that can publish its own novels
+//	@Tags			users
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		204	{}			"Account is now an author"
+//	@Failure		400	{object}	swagger.EnvelopeError	"Account already has another role"
+//	@Failure		401	{object}	swagger.EnvelopeError	"Unauthorize"
+//	@Failure		500	{object}	swagger.EnvelopeError	"Internal server error"
+//	@Router			/users/author [post]
+func (app *application) becomeAuthorHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if user.Role != store.RoleUser {
+		app.badRequestResponse(w, r, errors.New("only reader accounts can become authors"))
+		return
+	}
+
+	if err := app.store.Roles.AssignToUser(r.Context(), user.ID, store.RoleAuthor); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token text UNIQUE NOT NULL,
+    family_id uuid NOT NULL,
+    device text NOT NULL DEFAULT '',
+    token_version int NOT NULL,
+    expiry timestamp(0) with time zone NOT NULL,
+    revoked_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
\ No newline at end of file
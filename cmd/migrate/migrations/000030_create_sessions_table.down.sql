@@ -0,0 +1,4 @@
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS session_id;
+ALTER TABLE refresh_tokens ADD COLUMN device text NOT NULL DEFAULT '';
+
+DROP TABLE IF EXISTS sessions;
\ No newline at end of file
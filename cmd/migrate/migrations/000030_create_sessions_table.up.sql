@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS sessions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_name text NOT NULL DEFAULT '',
+    ip text NOT NULL DEFAULT '',
+    user_agent text NOT NULL DEFAULT '',
+    last_seen_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    revoked_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- refresh tokens issued before sessions existed cannot be tied to a device
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS device;
+ALTER TABLE refresh_tokens ADD COLUMN session_id bigint NOT NULL REFERENCES sessions(id) ON DELETE CASCADE;
\ No newline at end of file
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS two_factor_challenges;
+
+DROP TABLE IF EXISTS user_recovery_codes;
+
+ALTER TABLE users
+DROP COLUMN totp_secret,
+DROP COLUMN totp_enabled,
+DROP COLUMN totp_last_step;
\ No newline at end of file
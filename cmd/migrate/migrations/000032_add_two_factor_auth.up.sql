@@ -0,0 +1,20 @@
+ALTER TABLE users
+ADD COLUMN totp_secret text,
+ADD COLUMN totp_enabled boolean NOT NULL DEFAULT FALSE,
+ADD COLUMN totp_last_step bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code text NOT NULL,
+    used_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, code)
+);
+
+CREATE TABLE IF NOT EXISTS two_factor_challenges (
+    token text PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device text NOT NULL DEFAULT '',
+    expiry timestamp(0) with time zone NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1,54 @@
+CREATE TABLE IF NOT EXISTS roles (
+    id bigserial PRIMARY KEY,
+    name varchar(20) UNIQUE NOT NULL,
+    description text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id bigserial PRIMARY KEY,
+    name varchar(100) UNIQUE NOT NULL,
+    description text NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id bigint NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id bigint NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('user', 'Reader without staff access'),
+    ('admin', 'Full access'),
+    ('editor', 'Manages novels, genres and chapters'),
+    ('finance', 'Reads invoices')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+    ('admin:access', 'Open the admin dashboard'),
+    ('genres:write', 'Create, update and delete genres'),
+    ('novels:write', 'Create and update novels'),
+    ('novels:delete', 'Delete novels'),
+    ('chapters:publish', 'Create, update and delete chapters'),
+    ('chapters:read_locked', 'Read locked chapters without unlocking them'),
+    ('invoices:read', 'See every invoice'),
+    ('users:manage', 'Unlock users and manage their sessions'),
+    ('roles:manage', 'Assign roles to users')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON
+    r.name = 'admin'
+    OR (r.name = 'editor' AND p.name IN ('admin:access', 'genres:write', 'novels:write', 'chapters:publish', 'chapters:read_locked'))
+    OR (r.name = 'finance' AND p.name IN ('admin:access', 'invoices:read'))
+ON CONFLICT DO NOTHING;
+
+-- roles that exist on users but were never defined keep working without permissions
+INSERT INTO roles (name)
+SELECT DISTINCT role FROM users
+ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE users
+ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE;
\ No newline at end of file
@@ -0,0 +1,9 @@
+UPDATE users SET role = 'user' WHERE role = 'author';
+
+DELETE FROM roles WHERE name = 'author';
+
+DELETE FROM permissions WHERE name = 'novels:manage_any';
+
+DROP INDEX IF EXISTS novels_owner_id_idx;
+
+ALTER TABLE novels DROP COLUMN IF EXISTS owner_id;
\ No newline at end of file
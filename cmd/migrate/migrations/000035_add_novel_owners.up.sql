@@ -0,0 +1,20 @@
+ALTER TABLE novels
+ADD COLUMN owner_id bigint REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX novels_owner_id_idx ON novels (owner_id);
+
+INSERT INTO roles (name, description) VALUES
+    ('author', 'Publishes and manages their own novels')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+    ('novels:manage_any', 'Edit and delete novels and chapters owned by anyone')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON
+    (r.name IN ('admin', 'editor') AND p.name = 'novels:manage_any')
+    OR (r.name = 'author' AND p.name IN ('novels:write', 'novels:delete', 'chapters:publish'))
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE user_invitations DROP CONSTRAINT fk_user;
+
+ALTER TABLE user_invitations
+ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id);
+
+DROP INDEX IF EXISTS user_invitations_expiry_idx;
+
+ALTER TABLE users DROP COLUMN IF EXISTS activated_at;
\ No newline at end of file
@@ -0,0 +1,11 @@
+ALTER TABLE users
+ADD COLUMN activated_at timestamp(0) with time zone;
+
+UPDATE users SET activated_at = created_at WHERE is_active = true;
+
+CREATE INDEX user_invitations_expiry_idx ON user_invitations (expiry);
+
+ALTER TABLE user_invitations DROP CONSTRAINT fk_user;
+
+ALTER TABLE user_invitations
+ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
\ No newline at end of file
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS email_change_requests (
+    token text PRIMARY KEY,
+    user_id bigint UNIQUE NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    new_email citext NOT NULL,
+    expiry timestamp(0) with time zone NOT NULL
+);
\ No newline at end of file
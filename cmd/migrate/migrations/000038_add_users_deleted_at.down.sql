@@ -0,0 +1,17 @@
+DELETE FROM invoices WHERE user_id IS NULL;
+
+ALTER TABLE invoices DROP CONSTRAINT invoices_user_id_fkey;
+
+ALTER TABLE invoices
+ADD CONSTRAINT invoices_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id);
+
+ALTER TABLE invoices ALTER COLUMN user_id SET NOT NULL;
+
+ALTER TABLE forgot_pass_requests DROP CONSTRAINT fk_user;
+
+ALTER TABLE forgot_pass_requests
+ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id);
+
+DROP INDEX IF EXISTS users_deleted_at_idx;
+
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
\ No newline at end of file
@@ -0,0 +1,17 @@
+ALTER TABLE users
+ADD COLUMN deleted_at timestamp(0) with time zone;
+
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE forgot_pass_requests DROP CONSTRAINT fk_user;
+
+ALTER TABLE forgot_pass_requests
+ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+-- invoices are kept for accounting after their user is purged
+ALTER TABLE invoices ALTER COLUMN user_id DROP NOT NULL;
+
+ALTER TABLE invoices DROP CONSTRAINT invoices_user_id_fkey;
+
+ALTER TABLE invoices
+ADD CONSTRAINT invoices_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL;
\ No newline at end of file
@@ -0,0 +1,34 @@
+DROP INDEX IF EXISTS chapters_content_fts_idx;
+
+ALTER TABLE chapters DROP COLUMN IF EXISTS content_fts;
+
+DROP INDEX IF EXISTS novels_author_trgm_idx;
+
+DROP INDEX IF EXISTS novels_title_trgm_idx;
+
+DROP INDEX IF EXISTS novels_search_vector_idx;
+
+DROP TRIGGER IF EXISTS update_search_vector ON novels;
+
+DROP FUNCTION IF EXISTS novels_search_vector_trigger;
+
+ALTER TABLE novels DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE novels DROP COLUMN IF EXISTS language;
+
+ALTER TABLE novels ADD COLUMN title_fts tsvector;
+
+UPDATE novels
+SET title_fts = to_tsvector('english', coalesce(title, ''));
+
+CREATE INDEX novels_title_fts_idx ON novels USING GIN (title_fts);
+
+CREATE FUNCTION novels_title_fts_trigger() RETURNS trigger AS $$
+BEGIN
+  NEW.title_fts := to_tsvector('english', coalesce(NEW.title, ''));
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_title_fts BEFORE INSERT OR UPDATE
+ON novels FOR EACH ROW EXECUTE FUNCTION novels_title_fts_trigger();
\ No newline at end of file
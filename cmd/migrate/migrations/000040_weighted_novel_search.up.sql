@@ -0,0 +1,44 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE novels ADD COLUMN language text NOT NULL DEFAULT 'english'
+    CHECK (language IN ('english', 'indonesian', 'simple'));
+
+DROP TRIGGER IF EXISTS update_title_fts ON novels;
+
+DROP FUNCTION IF EXISTS novels_title_fts_trigger;
+
+DROP INDEX IF EXISTS novels_title_fts_idx;
+
+ALTER TABLE novels DROP COLUMN IF EXISTS title_fts;
+
+ALTER TABLE novels ADD COLUMN search_vector tsvector;
+
+CREATE FUNCTION novels_search_vector_trigger() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector(NEW.language::regconfig, coalesce(NEW.title, '')), 'A') ||
+    setweight(to_tsvector(NEW.language::regconfig, coalesce(NEW.author, '')), 'B') ||
+    setweight(to_tsvector(NEW.language::regconfig, coalesce(NEW.synopsis, '')), 'C');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_search_vector BEFORE INSERT OR UPDATE OF title, author, synopsis, language
+ON novels FOR EACH ROW EXECUTE FUNCTION novels_search_vector_trigger();
+
+UPDATE novels
+SET search_vector =
+    setweight(to_tsvector(language::regconfig, coalesce(title, '')), 'A') ||
+    setweight(to_tsvector(language::regconfig, coalesce(author, '')), 'B') ||
+    setweight(to_tsvector(language::regconfig, coalesce(synopsis, '')), 'C');
+
+CREATE INDEX novels_search_vector_idx ON novels USING GIN (search_vector);
+
+CREATE INDEX novels_title_trgm_idx ON novels USING GIN (title gin_trgm_ops);
+
+CREATE INDEX novels_author_trgm_idx ON novels USING GIN (author gin_trgm_ops);
+
+ALTER TABLE chapters ADD COLUMN content_fts tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED;
+
+CREATE INDEX chapters_content_fts_idx ON chapters USING GIN (content_fts);
\ No newline at end of file
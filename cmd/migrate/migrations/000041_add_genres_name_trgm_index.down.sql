@@ -0,0 +1 @@
+DROP INDEX IF EXISTS genres_name_trgm_idx;
\ No newline at end of file
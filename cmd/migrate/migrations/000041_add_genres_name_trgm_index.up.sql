@@ -0,0 +1 @@
+CREATE INDEX genres_name_trgm_idx ON genres USING GIN (name gin_trgm_ops);
\ No newline at end of file
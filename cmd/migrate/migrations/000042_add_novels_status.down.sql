@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS novels_status_published_at_idx;
+
+ALTER TABLE novels DROP CONSTRAINT IF EXISTS novels_published_at_check;
+
+ALTER TABLE novels DROP COLUMN IF EXISTS published_at;
+
+ALTER TABLE novels DROP COLUMN IF EXISTS status;
\ No newline at end of file
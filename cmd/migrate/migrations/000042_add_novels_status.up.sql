@@ -0,0 +1,13 @@
+ALTER TABLE novels ADD COLUMN status text NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'scheduled', 'published', 'hiatus', 'completed'));
+
+ALTER TABLE novels ADD COLUMN published_at timestamp(0) with time zone;
+
+UPDATE novels SET published_at = created_at;
+
+ALTER TABLE novels ALTER COLUMN status SET DEFAULT 'draft';
+
+ALTER TABLE novels ADD CONSTRAINT novels_published_at_check
+    CHECK (status = 'draft' OR published_at IS NOT NULL);
+
+CREATE INDEX novels_status_published_at_idx ON novels (status, published_at);
\ No newline at end of file
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS chapters_unreleased_publish_at_idx;
+
+ALTER TABLE chapters DROP COLUMN IF EXISTS published_at;
+
+ALTER TABLE chapters DROP COLUMN IF EXISTS publish_at;
\ No newline at end of file
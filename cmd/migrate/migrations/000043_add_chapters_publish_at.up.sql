@@ -0,0 +1,7 @@
+ALTER TABLE chapters ADD COLUMN publish_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
+
+ALTER TABLE chapters ADD COLUMN published_at timestamp(0) with time zone;
+
+UPDATE chapters SET publish_at = created_at, published_at = created_at;
+
+CREATE INDEX chapters_unreleased_publish_at_idx ON chapters (publish_at) WHERE published_at IS NULL;
\ No newline at end of file
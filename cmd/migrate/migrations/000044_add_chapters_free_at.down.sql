@@ -0,0 +1 @@
+ALTER TABLE chapters DROP COLUMN IF EXISTS free_at;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE chapters ADD COLUMN free_at timestamp(0) with time zone;
\ No newline at end of file
@@ -0,0 +1,9 @@
+DELETE FROM permissions WHERE name = 'coins:manage';
+
+DROP TABLE IF EXISTS coin_entries;
+
+DROP TABLE IF EXISTS coin_transactions;
+
+DROP FUNCTION IF EXISTS coin_ledger_append_only;
+
+DROP FUNCTION IF EXISTS coin_entries_balanced;
\ No newline at end of file
@@ -0,0 +1,73 @@
+CREATE TABLE IF NOT EXISTS coin_transactions (
+    id bigserial PRIMARY KEY,
+    -- no foreign key, the ledger has to outlive purged accounts
+    user_id bigint NOT NULL,
+    kind varchar(20) NOT NULL
+        CHECK (kind IN ('opening_balance', 'top_up', 'chapter_purchase', 'refund', 'admin_grant', 'bonus')),
+    balance bigint NOT NULL,
+    reference text NOT NULL DEFAULT '',
+    actor_id bigint,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX coin_transactions_user_id_idx ON coin_transactions (user_id, created_at, id);
+
+-- each transaction moves coins between a user's wallet and one of the
+-- platform's accounts, so its entries always sum to zero
+CREATE TABLE IF NOT EXISTS coin_entries (
+    id bigserial PRIMARY KEY,
+    transaction_id bigint NOT NULL REFERENCES coin_transactions (id),
+    account varchar(20) NOT NULL,
+    user_id bigint,
+    amount bigint NOT NULL CHECK (amount <> 0),
+    CHECK ((account = 'wallet') = (user_id IS NOT NULL))
+);
+
+CREATE INDEX coin_entries_transaction_id_idx ON coin_entries (transaction_id);
+CREATE INDEX coin_entries_wallet_idx ON coin_entries (user_id) WHERE account = 'wallet';
+
+CREATE FUNCTION coin_entries_balanced() RETURNS trigger AS $$
+BEGIN
+  IF (SELECT SUM(amount) FROM coin_entries WHERE transaction_id = NEW.transaction_id) <> 0 THEN
+    RAISE EXCEPTION 'coin transaction % does not balance', NEW.transaction_id;
+  END IF;
+  RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER coin_entries_balanced AFTER INSERT ON coin_entries
+DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE FUNCTION coin_entries_balanced();
+
+CREATE FUNCTION coin_ledger_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER coin_transactions_append_only BEFORE UPDATE OR DELETE OR TRUNCATE
+ON coin_transactions FOR EACH STATEMENT EXECUTE FUNCTION coin_ledger_append_only();
+
+CREATE TRIGGER coin_entries_append_only BEFORE UPDATE OR DELETE OR TRUNCATE
+ON coin_entries FOR EACH STATEMENT EXECUTE FUNCTION coin_ledger_append_only();
+
+-- open the ledger with the balances held so far
+WITH opened AS (
+    INSERT INTO coin_transactions (user_id, kind, balance)
+    SELECT id, 'opening_balance', coin FROM users WHERE coin <> 0
+    RETURNING id, user_id, balance
+)
+INSERT INTO coin_entries (transaction_id, account, user_id, amount)
+SELECT id, 'wallet', user_id, balance FROM opened
+UNION ALL
+SELECT id, 'equity', NULL, -balance FROM opened;
+
+INSERT INTO permissions (name, description) VALUES
+    ('coins:manage', 'Grant coins and reconcile the coin ledger')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'coins:manage'
+WHERE r.name IN ('admin', 'finance')
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- fails if a balance is already negative; settle it with a coin grant first
+ALTER TABLE users ADD CONSTRAINT users_coin_check CHECK (coin >= 0);
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id bigserial PRIMARY KEY,
+    provider varchar(20) NOT NULL,
+    event_id text NOT NULL,
+    invoice_id text NOT NULL DEFAULT '',
+    status text NOT NULL DEFAULT '',
+    payload jsonb NOT NULL,
+    attempts int NOT NULL DEFAULT 1,
+    error text NOT NULL DEFAULT '',
+    received_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    processed_at timestamp(0) with time zone,
+    UNIQUE (provider, event_id)
+);
+
+CREATE INDEX webhook_events_invoice_id_idx ON webhook_events (invoice_id);
\ No newline at end of file
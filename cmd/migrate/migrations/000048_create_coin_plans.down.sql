@@ -0,0 +1,8 @@
+DELETE FROM permissions WHERE name = 'plans:manage';
+
+ALTER TABLE invoices
+DROP COLUMN IF EXISTS bonus_coins,
+DROP COLUMN IF EXISTS coins,
+DROP COLUMN IF EXISTS currency;
+
+DROP TABLE IF EXISTS coin_plans;
\ No newline at end of file
@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS coin_plans (
+    id bigserial PRIMARY KEY,
+    code varchar(20) NOT NULL UNIQUE,
+    name varchar(100) NOT NULL,
+    description text NOT NULL DEFAULT '',
+    price numeric NOT NULL CHECK (price > 0),
+    currency char(3) NOT NULL DEFAULT 'IDR',
+    coins bigint NOT NULL CHECK (coins > 0),
+    bonus_coins bigint NOT NULL DEFAULT 0 CHECK (bonus_coins >= 0),
+    active_from timestamp(0) with time zone,
+    active_until timestamp(0) with time zone,
+    display_order int NOT NULL DEFAULT 0,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    CONSTRAINT coin_plans_active_window_check CHECK (active_until > active_from)
+);
+
+INSERT INTO coin_plans (code, name, description, price, coins, display_order) VALUES
+    ('lite', 'Topup Lite', 'Cocok untuk coba-coba dan pengguna baru.', 15000, 120, 1),
+    ('scroll', 'Topup Scroll', 'Paket menengah untuk penggunaan rutin.', 65000, 700, 2),
+    ('volume', 'Topup Volume', 'Paket besar, hemat dan praktis!', 100000, 1300, 3)
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE invoices
+ADD COLUMN currency char(3) NOT NULL DEFAULT 'IDR',
+ADD COLUMN coins bigint NOT NULL DEFAULT 0,
+ADD COLUMN bonus_coins bigint NOT NULL DEFAULT 0;
+
+UPDATE invoices i
+SET coins = p.coins
+FROM coin_plans p
+WHERE p.code = i.plan;
+
+INSERT INTO permissions (name, description) VALUES
+    ('plans:manage', 'Manage the coin plans sold in the top-up page')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'plans:manage'
+WHERE r.name IN ('admin', 'finance')
+ON CONFLICT DO NOTHING;
\ No newline at end of file
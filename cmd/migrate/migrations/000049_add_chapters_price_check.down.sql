@@ -0,0 +1 @@
+ALTER TABLE chapters DROP CONSTRAINT IF EXISTS chapters_price_check;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- chapters priced below zero would credit their buyers; make them free
+UPDATE chapters SET price = 0 WHERE price < 0;
+
+ALTER TABLE chapters ADD CONSTRAINT chapters_price_check CHECK (price >= 0);
\ No newline at end of file
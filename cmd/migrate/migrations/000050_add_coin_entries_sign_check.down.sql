@@ -0,0 +1,7 @@
+ALTER TABLE coin_entries DROP CONSTRAINT IF EXISTS coin_entries_wallet_sign_check;
+
+ALTER TABLE coin_entries DROP CONSTRAINT IF EXISTS coin_entries_transaction_kind_fkey;
+
+ALTER TABLE coin_entries DROP COLUMN IF EXISTS kind;
+
+ALTER TABLE coin_transactions DROP CONSTRAINT IF EXISTS coin_transactions_id_kind_key;
\ No newline at end of file
@@ -0,0 +1,28 @@
+-- entries carry their transaction's kind so the wallet side can be checked
+-- against it: purchases take coins, everything but opening balances adds them
+ALTER TABLE coin_transactions ADD CONSTRAINT coin_transactions_id_kind_key UNIQUE (id, kind);
+
+ALTER TABLE coin_entries ADD COLUMN kind varchar(20);
+
+ALTER TABLE coin_entries DISABLE TRIGGER coin_entries_append_only;
+
+UPDATE coin_entries e
+SET kind = t.kind
+FROM coin_transactions t
+WHERE t.id = e.transaction_id;
+
+ALTER TABLE coin_entries ENABLE TRIGGER coin_entries_append_only;
+
+ALTER TABLE coin_entries
+ALTER COLUMN kind SET NOT NULL,
+ADD CONSTRAINT coin_entries_transaction_kind_fkey
+    FOREIGN KEY (transaction_id, kind) REFERENCES coin_transactions (id, kind);
+
+-- NOT VALID: the ledger is append-only, so entries written before this check
+-- stay as they are and only new ones are held to it
+ALTER TABLE coin_entries ADD CONSTRAINT coin_entries_wallet_sign_check CHECK (
+    account <> 'wallet'
+    OR kind = 'opening_balance'
+    OR (kind = 'chapter_purchase' AND amount < 0)
+    OR (kind IN ('top_up', 'refund', 'admin_grant', 'bonus') AND amount > 0)
+) NOT VALID;
\ No newline at end of file
@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS bookmarks_user_id_created_at_idx;
+DROP INDEX IF EXISTS novels_chapter_count_idx;
+DROP INDEX IF EXISTS novels_bookmark_count_idx;
+DROP INDEX IF EXISTS novels_title_idx;
+DROP INDEX IF EXISTS novels_created_at_idx;
+DROP INDEX IF EXISTS novels_updated_at_idx;
+
+DROP TRIGGER IF EXISTS update_chapter_count ON chapters;
+
+DROP FUNCTION IF EXISTS novels_chapter_count_trigger;
+
+DROP TRIGGER IF EXISTS update_bookmark_count ON bookmarks;
+
+DROP FUNCTION IF EXISTS novels_bookmark_count_trigger;
+
+ALTER TABLE novels DROP COLUMN IF EXISTS chapter_count, DROP COLUMN IF EXISTS bookmark_count;
\ No newline at end of file
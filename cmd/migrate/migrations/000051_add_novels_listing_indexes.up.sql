@@ -0,0 +1,45 @@
+ALTER TABLE novels
+    ADD COLUMN bookmark_count BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN chapter_count BIGINT NOT NULL DEFAULT 0;
+
+UPDATE novels n
+SET bookmark_count = (SELECT COUNT(*) FROM bookmarks b WHERE b.novel_id = n.id),
+    chapter_count = (SELECT COUNT(*) FROM chapters c WHERE c.novel_id = n.id AND c.published_at IS NOT NULL);
+
+-- keep the counts the novel listings sort by in step with bookmarks and
+-- published chapters
+CREATE FUNCTION novels_bookmark_count_trigger() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'INSERT' THEN
+    UPDATE novels SET bookmark_count = bookmark_count + 1 WHERE id = NEW.novel_id;
+  ELSE
+    UPDATE novels SET bookmark_count = bookmark_count - 1 WHERE id = OLD.novel_id;
+  END IF;
+  RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_bookmark_count AFTER INSERT OR DELETE
+ON bookmarks FOR EACH ROW EXECUTE FUNCTION novels_bookmark_count_trigger();
+
+CREATE FUNCTION novels_chapter_count_trigger() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP <> 'INSERT' AND OLD.published_at IS NOT NULL THEN
+    UPDATE novels SET chapter_count = chapter_count - 1 WHERE id = OLD.novel_id;
+  END IF;
+  IF TG_OP <> 'DELETE' AND NEW.published_at IS NOT NULL THEN
+    UPDATE novels SET chapter_count = chapter_count + 1 WHERE id = NEW.novel_id;
+  END IF;
+  RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_chapter_count AFTER INSERT OR DELETE OR UPDATE OF novel_id, published_at
+ON chapters FOR EACH ROW EXECUTE FUNCTION novels_chapter_count_trigger();
+
+CREATE INDEX novels_updated_at_idx ON novels (updated_at, id);
+CREATE INDEX novels_created_at_idx ON novels (created_at, id);
+CREATE INDEX novels_title_idx ON novels (title, id);
+CREATE INDEX novels_bookmark_count_idx ON novels (bookmark_count, id);
+CREATE INDEX novels_chapter_count_idx ON novels (chapter_count, id);
+CREATE INDEX bookmarks_user_id_created_at_idx ON bookmarks (user_id, created_at, id);
\ No newline at end of file
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrWeakPassword = errors.New("password is too weak")
+
+// commonPasswords is always banned, on top of any list loaded from disk.
+var commonPasswords = []string{
+	"password", "password1", "password123", "passw0rd", "12345678", "123456789",
+	"1234567890", "qwerty123", "qwertyuiop", "11111111", "iloveyou", "letmein1",
+	"welcome1", "admin123", "abc12345", "sunshine", "football", "baseball",
+	"trustno1", "superman", "princess", "starwars", "dragon123", "monkey123",
+	"govel123", "novel123",
+}
+
+// PasswordPolicyError lists every rule a password broke so the reader can
+// fix them all at once.
+type PasswordPolicyError struct {
+	Problems []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password " + strings.Join(e.Problems, ", ")
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	banned   map[string]struct{}
+	breached *BreachedPasswords
+}
+
+func NewPasswordPolicy(minLength int, requireUpper, requireLower, requireDigit, requireSymbol bool) *PasswordPolicy {
+	p := &PasswordPolicy{
+		MinLength:     minLength,
+		RequireUpper:  requireUpper,
+		RequireLower:  requireLower,
+		RequireDigit:  requireDigit,
+		RequireSymbol: requireSymbol,
+		banned:        make(map[string]struct{}, len(commonPasswords)),
+	}
+
+	for _, pass := range commonPasswords {
+		p.banned[pass] = struct{}{}
+	}
+
+	return p
+}
+
+// LoadBannedList adds one password per line from path. Matching ignores case.
+func (p *PasswordPolicy) LoadBannedList(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p.banned[strings.ToLower(line)] = struct{}{}
+	}
+
+	return scanner.Err()
+}
+
+func (p *PasswordPolicy) SetBreachedPasswords(breached *BreachedPasswords) {
+	p.breached = breached
+}
+
+// Validate checks pass against the policy. userInputs are values such as the
+// username and email that must not appear in the password.
+func (p *PasswordPolicy) Validate(pass string, userInputs ...string) error {
+	var problems []string
+
+	if utf8.RuneCountInString(pass) < p.MinLength {
+		problems = append(problems, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range pass {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		problems = append(problems, "must contain an uppercase letter")
+	}
+
+	if p.RequireLower && !hasLower {
+		problems = append(problems, "must contain a lowercase letter")
+	}
+
+	if p.RequireDigit && !hasDigit {
+		problems = append(problems, "must contain a digit")
+	}
+
+	if p.RequireSymbol && !hasSymbol {
+		problems = append(problems, "must contain a symbol")
+	}
+
+	lower := strings.ToLower(pass)
+	for _, input := range userInputs {
+		input, _, _ = strings.Cut(strings.ToLower(input), "@")
+		if len(input) >= 3 && strings.Contains(lower, input) {
+			problems = append(problems, "must not contain your username or email")
+			break
+		}
+	}
+
+	if _, ok := p.banned[lower]; ok {
+		problems = append(problems, "is too common")
+	} else if p.breached != nil && p.breached.Contains(pass) {
+		problems = append(problems, "has appeared in a data breach")
+	}
+
+	if len(problems) > 0 {
+		return &PasswordPolicyError{Problems: problems}
+	}
+
+	return nil
+}
+
+// BreachedPasswords is an offline copy of a breached password corpus in the
+// Pwned Passwords format: uppercase SHA-1 hex, optionally followed by
+// ":count". Like the online k-anonymity API, hashes are bucketed by their
+// five character prefix and a lookup only ever scans one bucket.
+type BreachedPasswords struct {
+	ranges map[string][]string
+	count  int
+}
+
+const hashPrefixLen = 5
+
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadBreachedPasswords(f)
+}
+
+func ReadBreachedPasswords(r io.Reader) (*BreachedPasswords, error) {
+	b := &BreachedPasswords{ranges: make(map[string][]string)}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" {
+			continue
+		}
+
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("breached passwords line %d: not a SHA-1 hash", line)
+		}
+
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("breached passwords line %d: %w", line, err)
+		}
+
+		prefix := hash[:hashPrefixLen]
+		b.ranges[prefix] = append(b.ranges[prefix], hash[hashPrefixLen:])
+		b.count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, suffixes := range b.ranges {
+		sort.Strings(suffixes)
+	}
+
+	return b, nil
+}
+
+// Len is the number of hashes loaded.
+func (b *BreachedPasswords) Len() int {
+	return b.count
+}
+
+// Range returns the hash suffixes that share prefix, mirroring the
+// /range/{prefix} endpoint of the online API.
+func (b *BreachedPasswords) Range(prefix string) []string {
+	return b.ranges[strings.ToUpper(prefix)]
+}
+
+func (b *BreachedPasswords) Contains(pass string) bool {
+	sum := sha1.Sum([]byte(pass))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes := b.Range(hash[:hashPrefixLen])
+	suffix := hash[hashPrefixLen:]
+
+	i := sort.SearchStrings(suffixes, suffix)
+	return i < len(suffixes) && suffixes[i] == suffix
+}
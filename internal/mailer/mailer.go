@@ -5,16 +5,22 @@ import (
 	"crypto/tls"
 	"embed"
 	"fmt"
+	htmltemplate "html/template"
 	"net/smtp"
+	"strings"
 	"text/template"
 	"time"
 )
 
 const (
-	FromName              = "Govel"
-	maxRetries            = 3
-	UserWelcomeTemplate   = "user_invitations.tmpl"
-	ForgotPassReqTemplate = "reset_password_req.tmpl"
+	FromName                   = "Govel"
+	maxRetries                 = 3
+	UserWelcomeTemplate        = "user_invitations.tmpl"
+	ForgotPassReqTemplate      = "reset_password_req.tmpl"
+	AccountLockedTemplate      = "account_locked.tmpl"
+	EmailChangeConfirmTemplate = "email_change_confirm.tmpl"
+	EmailChangeNoticeTemplate  = "email_change_notice.tmpl"
+	ChapterReleasedTemplate    = "chapter_released.tmpl"
 )
 
 //go:embed "templates"
@@ -36,6 +42,9 @@ func NewSMTPMailer(host, port, username, password string) *SMTPMailer {
 	}
 }
 
+// Send renders templateFile for email and sends it. The body is HTML and is
+// rendered with html/template, so user-written values in it are escaped; line
+// breaks are dropped from the subject so it can't add headers.
 func (m *SMTPMailer) Send(templateFile, username, email string, data any) error {
 	tmpl, err := template.ParseFS(FS, "templates/"+templateFile)
 	if err != nil {
@@ -47,8 +56,13 @@ func (m *SMTPMailer) Send(templateFile, username, email string, data any) error
 		return err
 	}
 
+	bodyTmpl, err := htmltemplate.ParseFS(FS, "templates/"+templateFile)
+	if err != nil {
+		return err
+	}
+
 	body := new(bytes.Buffer)
-	if err := tmpl.ExecuteTemplate(body, "body", data); err != nil {
+	if err := bodyTmpl.ExecuteTemplate(body, "body", data); err != nil {
 		return err
 	}
 
@@ -57,7 +71,7 @@ func (m *SMTPMailer) Send(templateFile, username, email string, data any) error
 		FromName,
 		m.username,
 		email,
-		subject.String(),
+		headerValue(subject.String()),
 		body.String(),
 	)
 
@@ -74,6 +88,11 @@ func (m *SMTPMailer) Send(templateFile, username, email string, data any) error
 	return fmt.Errorf("failed to send email after %d attempts, error: %v", maxRetries, retryErr)
 }
 
+// headerValue folds s onto one line.
+func headerValue(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
 func (m *SMTPMailer) sendTLS(to, msg string) error {
 	addr := m.smtpHost + ":" + m.smtpPort
 
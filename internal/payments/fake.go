@@ -0,0 +1,267 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// FakeProvider is an in-process payment gateway for local development. Its
+// invoices link to a checkout page served by the API itself, where the invoice
+// can be paid, expired or failed; the outcome is sent to the webhook like a
+// real callback. Invoices are kept in memory and are lost on restart.
+type FakeProvider struct {
+	checkoutURL   string
+	webhookURL    string
+	returnURL     string
+	callbackToken string
+	client        *http.Client
+
+	mu       sync.Mutex
+	invoices map[string]*fakeInvoice
+}
+
+type fakeInvoice struct {
+	Invoice
+	Currency    string
+	Description string
+	Refunded    float64
+}
+
+// NewFakeProvider returns a fake gateway whose checkout pages are served under
+// checkoutURL, that sends callbacks to webhookURL and sends buyers back to
+// returnURL after checkout.
+func NewFakeProvider(checkoutURL, webhookURL, returnURL string) *FakeProvider {
+	return &FakeProvider{
+		checkoutURL:   checkoutURL,
+		webhookURL:    webhookURL,
+		returnURL:     returnURL,
+		callbackToken: randomID(),
+		client:        &http.Client{Timeout: 10 * time.Second},
+		invoices:      make(map[string]*fakeInvoice),
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) CreateInvoice(ctx context.Context, req InvoiceRequest) (*Invoice, error) {
+	id := "fake-" + randomID()
+
+	inv := &fakeInvoice{
+		Invoice: Invoice{
+			ID:         id,
+			ExternalID: req.ExternalID,
+			URL:        p.checkoutURL + "/" + id,
+			Status:     StatusPending,
+			Amount:     req.Amount,
+		},
+		Currency:    req.Currency,
+		Description: req.Description,
+	}
+
+	p.mu.Lock()
+	p.invoices[id] = inv
+	p.mu.Unlock()
+
+	invoice := inv.Invoice
+	return &invoice, nil
+}
+
+func (p *FakeProvider) ParseWebhook(r *http.Request) (*Event, error) {
+	return parseInvoiceCallback(r, p.callbackToken)
+}
+
+func (p *FakeProvider) GetInvoice(ctx context.Context, invoiceID string) (*Invoice, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	inv, ok := p.invoices[invoiceID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	invoice := inv.Invoice
+	return &invoice, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	inv, ok := p.invoices[req.InvoiceID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if inv.Status != StatusPaid && inv.Status != StatusSettled {
+		return nil, ErrNotRefundable
+	}
+
+	if req.Amount <= 0 || inv.Refunded+req.Amount > inv.Amount {
+		return nil, fmt.Errorf("refund of %v exceeds the %v left on the invoice", req.Amount, inv.Amount-inv.Refunded)
+	}
+
+	inv.Refunded += req.Amount
+
+	return &Refund{
+		ID:        "fake-refund-" + randomID(),
+		InvoiceID: req.InvoiceID,
+		Amount:    req.Amount,
+		Status:    "SUCCEEDED",
+	}, nil
+}
+
+// Routes serves the hosted checkout pages. It must be mounted at checkoutURL.
+func (p *FakeProvider) Routes() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/{invoiceID}", p.checkoutHandler)
+	r.Post("/{invoiceID}", p.completeCheckoutHandler)
+	return r
+}
+
+var checkoutTemplate = template.Must(template.New("checkout").Parse(`<!doctype html>
+<html>
+    <head>
+        <meta name="viewport" content="width=device-width"/>
+        <meta http-equiv="Content-Type" content="text/html; charset=UTF-8"/>
+        <title>Fake checkout</title>
+    </head>
+    <body style="font-family: sans-serif; max-width: 480px; margin: 48px auto;">
+        <h1>Fake checkout</h1>
+        <p>This payment gateway only exists for local development. No money is charged.</p>
+        <p><strong>{{ .Invoice.Description }}</strong><br/>{{ .Invoice.Currency }} {{ .Invoice.Amount }}</p>
+        <p>Status: <strong>{{ .Invoice.Status }}</strong></p>
+        {{ if .Error }}<p style="color: #b91c1c;">Webhook failed: {{ .Error }}</p>{{ end }}
+        {{ if eq .Invoice.Status "PENDING" }}
+        <form method="post">
+            <button name="status" value="PAID">Pay</button>
+            <button name="status" value="EXPIRED">Expire</button>
+            <button name="status" value="FAILED">Fail</button>
+        </form>
+        {{ else }}
+        <form method="post">
+            <button name="status" value="{{ .Invoice.Status }}">Resend webhook</button>
+        </form>
+        {{ end }}
+        <p><a href="{{ .ReturnURL }}">Back to Govel</a></p>
+    </body>
+</html>
+`))
+
+func (p *FakeProvider) checkoutHandler(w http.ResponseWriter, r *http.Request) {
+	inv, ok := p.snapshot(chi.URLParam(r, "invoiceID"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	p.renderCheckout(w, inv, nil)
+}
+
+// completeCheckoutHandler settles a pending invoice with the chosen status and
+// sends the callback. Posting the current status again redelivers it.
+func (p *FakeProvider) completeCheckoutHandler(w http.ResponseWriter, r *http.Request) {
+	invoiceID := chi.URLParam(r, "invoiceID")
+	status := r.FormValue("status")
+
+	switch status {
+	case StatusPaid, StatusExpired, StatusFailed:
+	default:
+		http.Error(w, "unknown status", http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	inv, ok := p.invoices[invoiceID]
+	if ok && inv.Status == StatusPending {
+		inv.Status = status
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	snapshot, _ := p.snapshot(invoiceID)
+	p.renderCheckout(w, snapshot, p.sendCallback(r.Context(), snapshot))
+}
+
+func (p *FakeProvider) snapshot(invoiceID string) (fakeInvoice, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	inv, ok := p.invoices[invoiceID]
+	if !ok {
+		return fakeInvoice{}, false
+	}
+
+	return *inv, true
+}
+
+func (p *FakeProvider) renderCheckout(w http.ResponseWriter, inv fakeInvoice, err error) {
+	data := map[string]any{
+		"Invoice":   inv,
+		"ReturnURL": p.returnURL,
+		"Error":     err,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := checkoutTemplate.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// sendCallback posts the invoice status to the webhook the way Xendit does.
+// Every delivery of a status carries the same webhook-id, as a redelivery
+// from Xendit would.
+func (p *FakeProvider) sendCallback(ctx context.Context, inv fakeInvoice) error {
+	body, err := json.Marshal(InvoiceCallback{
+		InvoiceID:  inv.ID,
+		ExternalID: inv.ExternalID,
+		Status:     inv.Status,
+		Amount:     inv.Amount,
+		Currency:   inv.Currency,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-callback-token", p.callbackToken)
+	req.Header.Set("webhook-id", inv.ID+":"+inv.Status)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+
+	return nil
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
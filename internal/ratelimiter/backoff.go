@@ -0,0 +1,101 @@
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+)
+
+const maxEntries = 10_000
+
+// Backoff tracks failures per key and blocks a key with an exponentially
+// growing delay once it reaches the threshold.
+type Backoff struct {
+	mu        sync.Mutex
+	threshold int
+	base      time.Duration
+	max       time.Duration
+	entries   map[string]*backoffEntry
+}
+
+type backoffEntry struct {
+	failures     int
+	blockedUntil time.Time
+	lastFailure  time.Time
+}
+
+func NewBackoff(threshold int, base, max time.Duration) *Backoff {
+	return &Backoff{
+		threshold: threshold,
+		base:      base,
+		max:       max,
+		entries:   make(map[string]*backoffEntry),
+	}
+}
+
+// Blocked reports whether key is currently blocked and for how long.
+func (b *Backoff) Blocked(key string) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.entries[key]
+	if !ok {
+		return 0, false
+	}
+
+	wait := time.Until(entry.blockedUntil)
+	if wait <= 0 {
+		return 0, false
+	}
+
+	return wait, true
+}
+
+// Fail records a failure for key and returns the delay now imposed on it.
+func (b *Backoff) Fail(key string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	entry, ok := b.entries[key]
+	if !ok || now.Sub(entry.lastFailure) > b.max {
+		if len(b.entries) >= maxEntries {
+			b.prune(now)
+		}
+
+		entry = &backoffEntry{}
+		b.entries[key] = entry
+	}
+
+	entry.failures++
+	entry.lastFailure = now
+
+	if entry.failures < b.threshold {
+		return 0
+	}
+
+	delay := b.base << (entry.failures - b.threshold)
+	if delay <= 0 || delay > b.max {
+		delay = b.max
+	}
+
+	entry.blockedUntil = now.Add(delay)
+
+	return delay
+}
+
+// Reset forgets every failure recorded for key.
+func (b *Backoff) Reset(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.entries, key)
+}
+
+func (b *Backoff) prune(now time.Time) {
+	for key, entry := range b.entries {
+		if now.Sub(entry.lastFailure) > b.max && now.After(entry.blockedUntil) {
+			delete(b.entries, key)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Audit actions recorded by the store.
+const (
+	AuditNovelCreate        = "novel.create"
+	AuditNovelUpdate        = "novel.update"
+	AuditNovelDelete        = "novel.delete"
+	AuditNovelStatus        = "novel.status"
+	AuditChapterCreate      = "chapter.create"
+	AuditChapterUpdate      = "chapter.update"
+	AuditChapterDelete      = "chapter.delete"
+	AuditGenreCreate        = "genre.create"
+	AuditGenreUpdate        = "genre.update"
+	AuditGenreDelete        = "genre.delete"
+	AuditUserUpdate         = "user.update"
+	AuditUserPasswordChange = "user.password_change"
+	AuditUserRoleAssign     = "user.role_assign"
+	AuditUserPasswordReset  = "user.password_reset"
+	AuditUserEmailChange    = "user.email_change"
+	AuditUserUnlock         = "user.unlock"
+	AuditUserDelete         = "user.delete"
+	AuditUserRestore        = "user.restore"
+	AuditUserSessionsRevoke = "user.sessions_revoke"
+	AuditUserCoinGrant      = "user.coin_grant"
+	AuditTwoFactorEnable    = "user.2fa_enable"
+	AuditTwoFactorDisable   = "user.2fa_disable"
+	AuditCoinPlanCreate     = "coin_plan.create"
+	AuditCoinPlanUpdate     = "coin_plan.update"
+	AuditCoinPlanDelete     = "coin_plan.delete"
+)
+
+// auditTables maps a target type to the row it snapshots.
+var auditTables = map[string]struct {
+	table string
+	key   string
+}{
+	"novel":     {"novels", "id"},
+	"chapter":   {"chapters", "slug"},
+	"genre":     {"genres", "id"},
+	"user":      {"users", "id"},
+	"coin_plan": {"coin_plans", "id"},
+}
+
+var (
+	auditRedacted = map[string]bool{"password": true, "totp_secret": true}
+	auditIgnored  = map[string]bool{"updated_at": true, "search_vector": true, "content_fts": true}
+)
+
+// auditMaxString keeps long text such as chapter content out of the log.
+const auditMaxString = 500
+
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	ActorID    *int64          `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	RequestID  string          `json:"request_id"`
+	IP         string          `json:"ip"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type AuditFilter struct {
+	ActorID    *int64
+	Action     string
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+}
+
+// AuditMeta describes who is behind the current request. The API puts it in
+// the request context so the store can attribute the changes it makes.
+type AuditMeta struct {
+	ActorID   *int64
+	RequestID string
+	IP        string
+}
+
+type auditKey struct{}
+
+func WithAuditMeta(ctx context.Context, meta AuditMeta) context.Context {
+	return context.WithValue(ctx, auditKey{}, meta)
+}
+
+func WithAuditActor(ctx context.Context, actorID int64) context.Context {
+	meta := auditMetaFromCtx(ctx)
+	meta.ActorID = &actorID
+	return WithAuditMeta(ctx, meta)
+}
+
+func auditMetaFromCtx(ctx context.Context) AuditMeta {
+	meta, _ := ctx.Value(auditKey{}).(AuditMeta)
+	return meta
+}
+
+type AuditStore struct {
+	db *pgxpool.Pool
+}
+
+func (s *AuditStore) GetAll(ctx context.Context, filter AuditFilter) ([]*AuditEvent, error) {
+	query := `
+		SELECT id, actor_id, action, target_type, target_id, before, after, request_id, ip, created_at
+		FROM audit_events
+		WHERE ($1::bigint IS NULL OR actor_id = $1)
+			AND ($2 = '' OR action = $2)
+			AND ($3 = '' OR target_type = $3)
+			AND ($4 = '' OR target_id = $4)
+			AND ($5::timestamptz IS NULL OR created_at >= $5)
+			AND ($6::timestamptz IS NULL OR created_at < $6)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $7
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.Query(
+		ctx,
+		query,
+		filter.ActorID,
+		filter.Action,
+		filter.TargetType,
+		filter.TargetID,
+		filter.From,
+		filter.To,
+		filter.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := []*AuditEvent{}
+	for rows.Next() {
+		var event AuditEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.ActorID,
+			&event.Action,
+			&event.TargetType,
+			&event.TargetID,
+			&event.Before,
+			&event.After,
+			&event.RequestID,
+			&event.IP,
+			&event.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// audited runs fn in tx and records how it changed the target row. id may be
+// a pointer that fn fills in, e.g. the ID of a row being created.
+func audited(ctx context.Context, tx pgx.Tx, action, targetType string, id any, fn func() error) error {
+	return auditedAs(ctx, tx, func(map[string]any) string { return action }, targetType, id, fn)
+}
+
+// auditedAs is audited with the action picked from the changed columns.
+func auditedAs(ctx context.Context, tx pgx.Tx, action func(changed map[string]any) string, targetType string, id any, fn func() error) error {
+	before, err := auditSnapshot(ctx, tx, targetType, id)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	after, err := auditSnapshot(ctx, tx, targetType, id)
+	if err != nil {
+		return err
+	}
+
+	before, after = auditDiff(before, after)
+
+	return recordAudit(ctx, tx, action(after), targetType, id, before, after)
+}
+
+func recordAudit(ctx context.Context, tx pgx.Tx, action, targetType string, id any, before, after map[string]any) error {
+	query := `
+		INSERT INTO audit_events (actor_id, action, target_type, target_id, before, after, request_id, ip)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	meta := auditMetaFromCtx(ctx)
+
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return err
+	}
+
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err = tx.Exec(
+		ctx,
+		query,
+		meta.ActorID,
+		action,
+		targetType,
+		fmt.Sprint(reflect.Indirect(reflect.ValueOf(id)).Interface()),
+		beforeJSON,
+		afterJSON,
+		meta.RequestID,
+		meta.IP,
+	)
+
+	return err
+}
+
+// auditJSON keeps a missing side SQL NULL rather than JSON null.
+func auditJSON(row map[string]any) ([]byte, error) {
+	if row == nil {
+		return nil, nil
+	}
+
+	return json.Marshal(row)
+}
+
+func auditSnapshot(ctx context.Context, tx pgx.Tx, targetType string, id any) (map[string]any, error) {
+	target, ok := auditTables[targetType]
+	if !ok {
+		return nil, fmt.Errorf("audit: unknown target type %q", targetType)
+	}
+
+	query := `SELECT to_jsonb(t) FROM ` + target.table + ` t WHERE ` + target.key + ` = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var row map[string]any
+	err := tx.QueryRow(ctx, query, id).Scan(&row)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return row, nil
+}
+
+// auditDiff keeps only the columns that changed. A created or deleted row is
+// kept whole on the side where it exists.
+func auditDiff(before, after map[string]any) (map[string]any, map[string]any) {
+	changedBefore := make(map[string]any)
+	changedAfter := make(map[string]any)
+
+	for key, value := range before {
+		if auditIgnored[key] {
+			continue
+		}
+
+		if after == nil || !reflect.DeepEqual(value, after[key]) {
+			changedBefore[key] = auditValue(key, value)
+		}
+	}
+
+	for key, value := range after {
+		if auditIgnored[key] {
+			continue
+		}
+
+		if before == nil || !reflect.DeepEqual(value, before[key]) {
+			changedAfter[key] = auditValue(key, value)
+		}
+	}
+
+	if before == nil {
+		changedBefore = nil
+	}
+
+	if after == nil {
+		changedAfter = nil
+	}
+
+	return changedBefore, changedAfter
+}
+
+func auditValue(key string, value any) any {
+	if auditRedacted[key] {
+		return "[redacted]"
+	}
+
+	if s, ok := value.(string); ok && len(s) > auditMaxString {
+		return fmt.Sprintf("[%d characters]", len(s))
+	}
+
+	return value
+}
@@ -106,6 +106,57 @@ func (b *BookmarkStore) GetByUserID(ctx context.Context, userID int64) ([]*Bookm
 	return bookmarks, nil
 }
 
+// bookmarkSortKeys are the novel orderings plus when the novel was bookmarked.
+var bookmarkSortKeys = func() map[string]sortKey {
+	keys := map[string]sortKey{
+		"bookmarked_at": {"bm.created_at", true, newTimeValue},
+	}
+
+	for name, key := range novelSortKeys {
+		keys[name] = key
+	}
+
+	return keys
+}()
+
+// List pages through userID's bookmarks. filter applies to the bookmarked
+// novels and Sort defaults to bookmarked_at unless searching.
+func (b *BookmarkStore) List(ctx context.Context, userID int64, filter NovelFilter) (*Page[*Bookmark], error) {
+	conditions, args, search := filter.conditions([]any{userID})
+
+	ks, err := filter.keyset(bookmarkSortKeys, "bookmarked_at", search)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions = append([]string{"bm.user_id = $1"}, conditions...)
+
+	listed := listing{
+		columns: `bm.id, bm.user_id, bm.novel_id, bm.created_at,
+			n.id, n.title, n.image_url, n.author, ` + novelStatusExpr,
+		from:       "bookmarks bm JOIN novels n ON n.id = bm.novel_id",
+		conditions: conditions,
+		args:       args,
+		id:         "bm.id",
+		withTotal:  filter.IncludeTotal,
+	}
+
+	return listPage(ctx, b.db, ks, listed, filter.Limit, func() (*Bookmark, []any) {
+		bookmark := Bookmark{Novel: &Novel{}}
+		return &bookmark, []any{
+			&bookmark.ID,
+			&bookmark.UserID,
+			&bookmark.NovelID,
+			&bookmark.CreatedAt,
+			&bookmark.Novel.ID,
+			&bookmark.Novel.Title,
+			&bookmark.Novel.ImageURL,
+			&bookmark.Novel.Author,
+			&bookmark.Novel.Status,
+		}
+	})
+}
+
 func (b *BookmarkStore) GetByID(ctx context.Context, bookmarkID int64) (*Bookmark, error) {
 	query := `
 		SELECT id, user_id, novel_id, created_at
@@ -158,3 +209,39 @@ func (b *BookmarkStore) Delete(ctx context.Context, bookmarkID int64) error {
 
 	return nil
 }
+
+// GetSubscribers returns the active users who bookmarked the novel, to be
+// told about its new chapters.
+func (b *BookmarkStore) GetSubscribers(ctx context.Context, novelID int64) ([]*User, error) {
+	query := `
+		SELECT u.id, u.username, u.email
+		FROM bookmarks b
+		JOIN users u ON u.id = b.user_id
+		WHERE b.novel_id = $1 AND u.is_active = true AND u.deleted_at IS NULL
+		ORDER BY u.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := b.db.Query(ctx, query, novelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
@@ -13,21 +13,34 @@ var (
 	ErrDuplicateSlug = errors.New("a chapter with this slug already exists")
 )
 
+// chapterLockedExpr is whether a chapter is behind the paywall right now. An
+// early access chapter unlocks for everyone once its free_at has passed.
+const chapterLockedExpr = `(is_locked AND (free_at IS NULL OR free_at > now()))`
+
 type Chapter struct {
-	ID            int64     `json:"id"`
-	NovelID       int64     `json:"novel_id"`
-	Slug          string    `json:"slug"`
-	Title         string    `json:"title"`
-	Content       string    `json:"content"`
-	ChapterNumber float64   `json:"chapter_number"`
-	IsLocked      bool      `json:"is_locked"`
-	IsPaid        bool      `json:"is_paid"`
-	Price         int       `json:"price"`
-	IsRead        bool      `json:"is_read"`
-	PrevSlug      *string   `json:"prev_slug,omitempty"`
-	NextSlug      *string   `json:"next_slug,omitempty"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID            int64      `json:"id"`
+	NovelID       int64      `json:"novel_id"`
+	Slug          string     `json:"slug"`
+	Title         string     `json:"title"`
+	Content       string     `json:"content"`
+	ChapterNumber float64    `json:"chapter_number"`
+	IsLocked      bool       `json:"is_locked"`
+	IsPaid        bool       `json:"is_paid"`
+	Price         int        `json:"price"`
+	FreeAt        *time.Time `json:"free_at"`
+	IsRead        bool       `json:"is_read"`
+	PrevSlug      *string    `json:"prev_slug,omitempty"`
+	NextSlug      *string    `json:"next_slug,omitempty"`
+	PublishAt     time.Time  `json:"publish_at"`
+	PublishedAt   *time.Time `json:"published_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// IsReleased reports whether readers can see the chapter. A chapter scheduled
+// for later stays hidden until the release scheduler publishes it.
+func (c *Chapter) IsReleased() bool {
+	return c.PublishedAt != nil
 }
 
 type ChaptersStore struct {
@@ -35,15 +48,24 @@ type ChaptersStore struct {
 }
 
 func (c *ChaptersStore) Create(ctx context.Context, chapter *Chapter) error {
+	return withTx(c.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditChapterCreate, "chapter", chapter.Slug, func() error {
+			return c.create(ctx, tx, chapter)
+		})
+	})
+}
+
+func (c *ChaptersStore) create(ctx context.Context, tx pgx.Tx, chapter *Chapter) error {
 	query := `
-		INSERT INTO chapters (novel_id, slug, title, content, chapter_number, is_locked, price)
-		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at
+		INSERT INTO chapters (novel_id, slug, title, content, chapter_number, is_locked, price, free_at, publish_at, published_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CASE WHEN $9 <= NOW() THEN NOW() END)
+		RETURNING id, ` + chapterLockedExpr + `, publish_at, published_at, created_at
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := c.db.QueryRow(
+	err := tx.QueryRow(
 		ctx,
 		query,
 		chapter.NovelID,
@@ -53,7 +75,9 @@ func (c *ChaptersStore) Create(ctx context.Context, chapter *Chapter) error {
 		chapter.ChapterNumber,
 		chapter.IsLocked,
 		chapter.Price,
-	).Scan(&chapter.ID, &chapter.CreatedAt)
+		chapter.FreeAt,
+		chapter.PublishAt,
+	).Scan(&chapter.ID, &chapter.IsLocked, &chapter.PublishAt, &chapter.PublishedAt, &chapter.CreatedAt)
 
 	if err != nil {
 		switch {
@@ -67,15 +91,20 @@ func (c *ChaptersStore) Create(ctx context.Context, chapter *Chapter) error {
 	return nil
 }
 
-func (c *ChaptersStore) GetBySlug(ctx context.Context, slug string) (*Chapter, error) {
+// GetBySlug returns the chapter with its neighbours. Unreleased chapters are
+// only found, and only linked as neighbours, when includeUnreleased is set.
+func (c *ChaptersStore) GetBySlug(ctx context.Context, slug string, includeUnreleased bool) (*Chapter, error) {
 	query := `
-		SELECT id, novel_id, slug, title, content, chapter_number, is_locked, price, created_at, updated_at,
-		       prev_slug, next_slug
+		SELECT id, novel_id, slug, title, content, chapter_number, is_locked, price, free_at, publish_at, published_at,
+		       created_at, updated_at, prev_slug, next_slug
 		FROM (
-			SELECT id, novel_id, slug, title, content, chapter_number, is_locked, price, created_at, updated_at,
+			SELECT id, novel_id, slug, title, content, chapter_number, ` + chapterLockedExpr + ` AS is_locked, price,
+			       free_at, publish_at, published_at,
+			       created_at, updated_at,
 			       LAG(slug) OVER (PARTITION BY novel_id ORDER BY chapter_number ASC) AS prev_slug,
 			       LEAD(slug) OVER (PARTITION BY novel_id ORDER BY chapter_number ASC) AS next_slug
 			FROM chapters
+			WHERE $2 OR published_at IS NOT NULL
 		) AS subquery
 		WHERE slug = $1
 	`
@@ -85,7 +114,7 @@ func (c *ChaptersStore) GetBySlug(ctx context.Context, slug string) (*Chapter, e
 
 	var chapter Chapter
 
-	err := c.db.QueryRow(ctx, query, slug).Scan(
+	err := c.db.QueryRow(ctx, query, slug, includeUnreleased).Scan(
 		&chapter.ID,
 		&chapter.NovelID,
 		&chapter.Slug,
@@ -94,6 +123,9 @@ func (c *ChaptersStore) GetBySlug(ctx context.Context, slug string) (*Chapter, e
 		&chapter.ChapterNumber,
 		&chapter.IsLocked,
 		&chapter.Price,
+		&chapter.FreeAt,
+		&chapter.PublishAt,
+		&chapter.PublishedAt,
 		&chapter.CreatedAt,
 		&chapter.UpdatedAt,
 		&chapter.PrevSlug,
@@ -111,17 +143,26 @@ func (c *ChaptersStore) GetBySlug(ctx context.Context, slug string) (*Chapter, e
 }
 
 func (c *ChaptersStore) Update(ctx context.Context, chapter *Chapter) error {
+	return withTx(c.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditChapterUpdate, "chapter", chapter.Slug, func() error {
+			return c.update(ctx, tx, chapter)
+		})
+	})
+}
+
+func (c *ChaptersStore) update(ctx context.Context, tx pgx.Tx, chapter *Chapter) error {
 	query := `
 		update chapters
-		SET title = $1, content = $2, chapter_number = $3, is_locked = $4, price = $5, updated_at = $6
-		WHERE slug = $7
-		RETURNING id, slug, created_at, updated_at
+		SET title = $1, content = $2, chapter_number = $3, is_locked = $4, price = $5, free_at = $6, publish_at = $7,
+			updated_at = $8
+		WHERE slug = $9
+		RETURNING id, slug, ` + chapterLockedExpr + `, free_at, publish_at, published_at, created_at, updated_at
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := c.db.QueryRow(
+	err := tx.QueryRow(
 		ctx,
 		query,
 		chapter.Title,
@@ -129,11 +170,17 @@ func (c *ChaptersStore) Update(ctx context.Context, chapter *Chapter) error {
 		chapter.ChapterNumber,
 		chapter.IsLocked,
 		chapter.Price,
+		chapter.FreeAt,
+		chapter.PublishAt,
 		chapter.UpdatedAt,
 		chapter.Slug,
 	).Scan(
 		&chapter.ID,
 		&chapter.Slug,
+		&chapter.IsLocked,
+		&chapter.FreeAt,
+		&chapter.PublishAt,
+		&chapter.PublishedAt,
 		&chapter.CreatedAt,
 		&chapter.UpdatedAt,
 	)
@@ -151,24 +198,31 @@ func (c *ChaptersStore) Update(ctx context.Context, chapter *Chapter) error {
 }
 
 func (c *ChaptersStore) Delete(ctx context.Context, slug string) error {
-	query := `DELETE FROM chapters WHERE slug = $1`
+	return withTx(c.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditChapterDelete, "chapter", slug, func() error {
+			query := `DELETE FROM chapters WHERE slug = $1`
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+			defer cancel()
 
-	cmdTag, err := c.db.Exec(ctx, query, slug)
-	if err != nil {
-		return err
-	}
+			cmdTag, err := tx.Exec(ctx, query, slug)
+			if err != nil {
+				return err
+			}
 
-	if cmdTag.RowsAffected() == 0 {
-		return ErrNotFound
-	}
+			if cmdTag.RowsAffected() == 0 {
+				return ErrNotFound
+			}
 
-	return nil
+			return nil
+		})
+	})
 }
 
-func (c *ChaptersStore) GetChaptersFromNovelID(ctx context.Context, novelID int64, userID int64) ([]*Chapter, error) {
+// GetChaptersFromNovelID lists the chapters of a novel, newest first, with the
+// read and unlock state of userID. Unreleased chapters are left out unless
+// includeUnreleased is set.
+func (c *ChaptersStore) GetChaptersFromNovelID(ctx context.Context, novelID int64, userID int64, includeUnreleased bool) ([]*Chapter, error) {
 	query := `
 		SELECT 
 			c.id, 
@@ -176,8 +230,11 @@ func (c *ChaptersStore) GetChaptersFromNovelID(ctx context.Context, novelID int6
 			c.slug, 
 			c.title, 
 			c.chapter_number,
-			c.is_locked, 
+			` + chapterLockedExpr + ` AS is_locked,
 			c.price, 
+			c.free_at,
+			c.publish_at,
+			c.published_at,
 			c.created_at, 
 			c.updated_at,
 			EXISTS (
@@ -191,7 +248,7 @@ func (c *ChaptersStore) GetChaptersFromNovelID(ctx context.Context, novelID int6
 				WHERE u.chapter_slug = c.slug AND u.user_id = $2
 			) AS is_paid
 		FROM chapters c
-		WHERE c.novel_id = $1
+		WHERE c.novel_id = $1 AND ($3 OR c.published_at IS NOT NULL)
 		ORDER BY c.chapter_number DESC;
 	`
 
@@ -203,6 +260,7 @@ func (c *ChaptersStore) GetChaptersFromNovelID(ctx context.Context, novelID int6
 		query,
 		novelID,
 		userID,
+		includeUnreleased,
 	)
 
 	if err != nil {
@@ -222,6 +280,9 @@ func (c *ChaptersStore) GetChaptersFromNovelID(ctx context.Context, novelID int6
 			&chapter.ChapterNumber,
 			&chapter.IsLocked,
 			&chapter.Price,
+			&chapter.FreeAt,
+			&chapter.PublishAt,
+			&chapter.PublishedAt,
 			&chapter.CreatedAt,
 			&chapter.UpdatedAt,
 			&chapter.IsRead,
@@ -241,3 +302,87 @@ func (c *ChaptersStore) GetChaptersFromNovelID(ctx context.Context, novelID int6
 
 	return chapters, nil
 }
+
+// ReleaseDue publishes every chapter whose publish time has come and bumps the
+// updated_at of their novels, returning the chapters released. Rows another
+// instance is already releasing are skipped.
+func (c *ChaptersStore) ReleaseDue(ctx context.Context) ([]*Chapter, error) {
+	var chapters []*Chapter
+
+	err := withTx(c.db, ctx, func(tx pgx.Tx) error {
+		var err error
+		chapters, err = c.releaseDue(ctx, tx)
+		if err != nil || len(chapters) == 0 {
+			return err
+		}
+
+		novelIDs := make([]int64, len(chapters))
+		for i, chapter := range chapters {
+			novelIDs[i] = chapter.NovelID
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err = tx.Exec(ctx, `UPDATE novels SET updated_at = NOW() WHERE id = ANY($1)`, novelIDs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return chapters, nil
+}
+
+func (c *ChaptersStore) releaseDue(ctx context.Context, tx pgx.Tx) ([]*Chapter, error) {
+	query := `
+		UPDATE chapters
+		SET published_at = NOW()
+		WHERE id IN (
+			SELECT id FROM chapters
+			WHERE published_at IS NULL AND publish_at <= NOW()
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, novel_id, slug, title, chapter_number, ` + chapterLockedExpr + `, price, free_at, publish_at, published_at,
+			created_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := tx.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chapters []*Chapter
+	for rows.Next() {
+		var chapter Chapter
+		err := rows.Scan(
+			&chapter.ID,
+			&chapter.NovelID,
+			&chapter.Slug,
+			&chapter.Title,
+			&chapter.ChapterNumber,
+			&chapter.IsLocked,
+			&chapter.Price,
+			&chapter.FreeAt,
+			&chapter.PublishAt,
+			&chapter.PublishedAt,
+			&chapter.CreatedAt,
+			&chapter.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		chapters = append(chapters, &chapter)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return chapters, nil
+}
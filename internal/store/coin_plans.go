@@ -0,0 +1,237 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrDuplicatePlanCode = errors.New("a plan with that code already exist")
+
+// CoinPlan is a coin package sold in the top-up page. It is on sale between
+// ActiveFrom and ActiveUntil, either of which may be open.
+type CoinPlan struct {
+	ID           int64      `json:"id"`
+	Code         string     `json:"code"`
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	Price        float64    `json:"price"`
+	Currency     string     `json:"currency"`
+	Coins        int64      `json:"coins"`
+	BonusCoins   int64      `json:"bonus_coins"`
+	ActiveFrom   *time.Time `json:"active_from"`
+	ActiveUntil  *time.Time `json:"active_until"`
+	DisplayOrder int        `json:"display_order"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+const coinPlanColumns = `
+	id, code, name, description, price, currency, coins, bonus_coins,
+	active_from, active_until, display_order, created_at, updated_at
+`
+
+const coinPlanActive = `
+	(active_from IS NULL OR active_from <= NOW()) AND (active_until IS NULL OR active_until > NOW())
+`
+
+func (p *CoinPlan) scanDest() []any {
+	return []any{
+		&p.ID,
+		&p.Code,
+		&p.Name,
+		&p.Description,
+		&p.Price,
+		&p.Currency,
+		&p.Coins,
+		&p.BonusCoins,
+		&p.ActiveFrom,
+		&p.ActiveUntil,
+		&p.DisplayOrder,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	}
+}
+
+type CoinPlansStore struct {
+	db *pgxpool.Pool
+}
+
+func (s *CoinPlansStore) Create(ctx context.Context, plan *CoinPlan) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditCoinPlanCreate, "coin_plan", &plan.ID, func() error {
+			query := `
+				INSERT INTO coin_plans (
+					code, name, description, price, currency, coins, bonus_coins,
+					active_from, active_until, display_order
+				)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+				RETURNING ` + coinPlanColumns
+
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+			defer cancel()
+
+			err := tx.QueryRow(
+				ctx,
+				query,
+				plan.Code,
+				plan.Name,
+				plan.Description,
+				plan.Price,
+				plan.Currency,
+				plan.Coins,
+				plan.BonusCoins,
+				plan.ActiveFrom,
+				plan.ActiveUntil,
+				plan.DisplayOrder,
+			).Scan(plan.scanDest()...)
+			if err != nil {
+				switch {
+				case err.Error() == `ERROR: duplicate key value violates unique constraint "coin_plans_code_key" (SQLSTATE 23505)`:
+					return ErrDuplicatePlanCode
+				default:
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+}
+
+// GetAll returns every plan, including those not on sale, in display order.
+func (s *CoinPlansStore) GetAll(ctx context.Context) ([]*CoinPlan, error) {
+	return s.list(ctx, `SELECT `+coinPlanColumns+` FROM coin_plans ORDER BY display_order, id`)
+}
+
+// GetActive returns the plans on sale now, in display order.
+func (s *CoinPlansStore) GetActive(ctx context.Context) ([]*CoinPlan, error) {
+	return s.list(ctx, `SELECT `+coinPlanColumns+` FROM coin_plans WHERE `+coinPlanActive+` ORDER BY display_order, id`)
+}
+
+func (s *CoinPlansStore) list(ctx context.Context, query string) ([]*CoinPlan, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []*CoinPlan{}
+	for rows.Next() {
+		var plan CoinPlan
+		if err := rows.Scan(plan.scanDest()...); err != nil {
+			return nil, err
+		}
+		plans = append(plans, &plan)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return plans, nil
+}
+
+func (s *CoinPlansStore) GetByID(ctx context.Context, planID int64) (*CoinPlan, error) {
+	return s.get(ctx, `SELECT `+coinPlanColumns+` FROM coin_plans WHERE id = $1`, planID)
+}
+
+// GetActiveByCode returns the plan sold under code, or ErrNotFound when there
+// is none or it is not on sale now.
+func (s *CoinPlansStore) GetActiveByCode(ctx context.Context, code string) (*CoinPlan, error) {
+	return s.get(ctx, `SELECT `+coinPlanColumns+` FROM coin_plans WHERE code = $1 AND `+coinPlanActive, code)
+}
+
+func (s *CoinPlansStore) get(ctx context.Context, query string, arg any) (*CoinPlan, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var plan CoinPlan
+
+	err := s.db.QueryRow(ctx, query, arg).Scan(plan.scanDest()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &plan, nil
+}
+
+// Update changes the plan for new invoices. Invoices already created keep the
+// price and coins they were made with.
+func (s *CoinPlansStore) Update(ctx context.Context, plan *CoinPlan) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditCoinPlanUpdate, "coin_plan", plan.ID, func() error {
+			query := `
+				UPDATE coin_plans
+				SET code = $1, name = $2, description = $3, price = $4, currency = $5,
+					coins = $6, bonus_coins = $7, active_from = $8, active_until = $9,
+					display_order = $10, updated_at = NOW()
+				WHERE id = $11
+				RETURNING ` + coinPlanColumns
+
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+			defer cancel()
+
+			err := tx.QueryRow(
+				ctx,
+				query,
+				plan.Code,
+				plan.Name,
+				plan.Description,
+				plan.Price,
+				plan.Currency,
+				plan.Coins,
+				plan.BonusCoins,
+				plan.ActiveFrom,
+				plan.ActiveUntil,
+				plan.DisplayOrder,
+				plan.ID,
+			).Scan(plan.scanDest()...)
+			if err != nil {
+				switch {
+				case errors.Is(err, pgx.ErrNoRows):
+					return ErrNotFound
+				case err.Error() == `ERROR: duplicate key value violates unique constraint "coin_plans_code_key" (SQLSTATE 23505)`:
+					return ErrDuplicatePlanCode
+				default:
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+}
+
+func (s *CoinPlansStore) Delete(ctx context.Context, planID int64) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditCoinPlanDelete, "coin_plan", planID, func() error {
+			query := `DELETE FROM coin_plans WHERE id = $1`
+
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+			defer cancel()
+
+			cmdTag, err := tx.Exec(ctx, query, planID)
+			if err != nil {
+				return err
+			}
+
+			if cmdTag.RowsAffected() == 0 {
+				return ErrNotFound
+			}
+
+			return nil
+		})
+	})
+}
@@ -0,0 +1,287 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrInsufficientCoin = errors.New("insufficient coin")
+	ErrInvalidPrice     = errors.New("price can't be negative")
+	ErrInvalidAmount    = errors.New("amount has the wrong sign for the transaction kind")
+)
+
+// Kinds of coin transactions.
+const (
+	CoinOpeningBalance  = "opening_balance"
+	CoinTopUp           = "top_up"
+	CoinChapterPurchase = "chapter_purchase"
+	CoinRefund          = "refund"
+	CoinAdminGrant      = "admin_grant"
+	CoinBonus           = "bonus"
+)
+
+// CoinGrantKinds are the transactions staff can make by hand.
+var CoinGrantKinds = []string{CoinAdminGrant, CoinBonus, CoinRefund}
+
+// coinAccounts is the platform account on the other side of the user's
+// wallet for each kind of transaction.
+var coinAccounts = map[string]string{
+	CoinOpeningBalance:  "equity",
+	CoinTopUp:           "payments",
+	CoinChapterPurchase: "sales",
+	CoinRefund:          "sales",
+	CoinAdminGrant:      "grants",
+	CoinBonus:           "promotions",
+}
+
+// coinSigns is the sign a kind of transaction must have on the wallet side:
+// purchases take coins, top-ups, refunds, grants and bonuses add them and an
+// opening balance can go either way.
+var coinSigns = map[string]int64{
+	CoinOpeningBalance:  0,
+	CoinTopUp:           1,
+	CoinChapterPurchase: -1,
+	CoinRefund:          1,
+	CoinAdminGrant:      1,
+	CoinBonus:           1,
+}
+
+// CoinTransaction is one change of a user's coin balance. Amount is what it
+// added to the wallet, negative when coins were spent, and Balance is the
+// wallet after it.
+type CoinTransaction struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Kind      string    `json:"kind"`
+	Amount    int64     `json:"amount"`
+	Balance   int64     `json:"balance"`
+	Reference string    `json:"reference"`
+	ActorID   *int64    `json:"actor_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CoinMismatch is a user whose cached balance disagrees with the ledger.
+type CoinMismatch struct {
+	UserID        int64 `json:"user_id"`
+	Balance       int64 `json:"balance"`
+	LedgerBalance int64 `json:"ledger_balance"`
+}
+
+var coinSortKeys = map[string]sortKey{
+	"created_at": {"t.created_at", true, newTimeValue},
+}
+
+type CoinsStore struct {
+	db *pgxpool.Pool
+}
+
+// record applies txn to the user's balance and writes it to the ledger in tx,
+// filling in its ID, Balance and CreatedAt. Nothing is recorded for a zero
+// amount, such as a chapter priced at 0, and an amount whose sign doesn't fit
+// the kind is refused with ErrInvalidAmount.
+//
+// A debit only applies while the balance covers it, and ErrInsufficientCoin is
+// returned when it doesn't. Concurrent updates of the same user wait on the
+// row lock and recheck the balance, so parallel purchases can't overdraw it.
+func (c *CoinsStore) record(ctx context.Context, tx pgx.Tx, txn *CoinTransaction) error {
+	account, ok := coinAccounts[txn.Kind]
+	if !ok {
+		return fmt.Errorf("coins: unknown transaction kind %q", txn.Kind)
+	}
+
+	if txn.Amount == 0 {
+		return nil
+	}
+
+	if sign := coinSigns[txn.Kind]; sign != 0 && (txn.Amount > 0) != (sign > 0) {
+		return fmt.Errorf("coins: %s of %d: %w", txn.Kind, txn.Amount, ErrInvalidAmount)
+	}
+
+	if txn.ActorID == nil {
+		txn.ActorID = auditMetaFromCtx(ctx).ActorID
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRow(
+		ctx,
+		`UPDATE users SET coin = coin + $1 WHERE id = $2 AND coin + $1 >= 0 RETURNING coin`,
+		txn.Amount,
+		txn.UserID,
+	).Scan(&txn.Balance)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, pgx.ErrNoRows) && txn.Amount < 0:
+			return ErrInsufficientCoin
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrNotFound
+		case errors.As(err, &pgErr) && pgErr.Code == "23514" && pgErr.ConstraintName == "users_coin_check":
+			return ErrInsufficientCoin
+		default:
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO coin_transactions (user_id, kind, balance, reference, actor_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	err = tx.QueryRow(
+		ctx,
+		query,
+		txn.UserID,
+		txn.Kind,
+		txn.Balance,
+		txn.Reference,
+		txn.ActorID,
+	).Scan(&txn.ID, &txn.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO coin_entries (transaction_id, kind, account, user_id, amount)
+		VALUES ($1, $2, 'wallet', $3, $4), ($1, $2, $5, NULL, -$4)
+	`
+
+	_, err = tx.Exec(ctx, query, txn.ID, txn.Kind, txn.UserID, txn.Amount, account)
+	return err
+}
+
+// Grant is a transaction made by staff, such as a refund or a bonus.
+func (c *CoinsStore) Grant(ctx context.Context, txn *CoinTransaction) error {
+	return withTx(c.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditUserCoinGrant, "user", txn.UserID, func() error {
+			return c.record(ctx, tx, txn)
+		})
+	})
+}
+
+// History pages through userID's coin transactions, newest first. The total is
+// only counted when withTotal is set.
+func (c *CoinsStore) History(ctx context.Context, userID int64, cursor string, limit int, withTotal bool) (*Page[*CoinTransaction], error) {
+	ks, err := newKeyset(coinSortKeys, "created_at", "", cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := listing{
+		columns:    `t.id, t.user_id, t.kind, e.amount, t.balance, t.reference, t.actor_id, t.created_at`,
+		from:       `coin_transactions t JOIN coin_entries e ON e.transaction_id = t.id AND e.account = 'wallet'`,
+		conditions: []string{"t.user_id = $1"},
+		args:       []any{userID},
+		id:         "t.id",
+		withTotal:  withTotal,
+	}
+
+	return listPage(ctx, c.db, ks, listed, limit, func() (*CoinTransaction, []any) {
+		var txn CoinTransaction
+		return &txn, []any{
+			&txn.ID,
+			&txn.UserID,
+			&txn.Kind,
+			&txn.Amount,
+			&txn.Balance,
+			&txn.Reference,
+			&txn.ActorID,
+			&txn.CreatedAt,
+		}
+	})
+}
+
+// GetByUserID returns every coin transaction of userID, oldest first.
+func (c *CoinsStore) GetByUserID(ctx context.Context, userID int64) ([]*CoinTransaction, error) {
+	query := `
+		SELECT t.id, t.user_id, t.kind, e.amount, t.balance, t.reference, t.actor_id, t.created_at
+		FROM coin_transactions t
+		JOIN coin_entries e ON e.transaction_id = t.id AND e.account = 'wallet'
+		WHERE t.user_id = $1
+		ORDER BY t.created_at, t.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := c.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []*CoinTransaction{}
+	for rows.Next() {
+		var txn CoinTransaction
+		err := rows.Scan(
+			&txn.ID,
+			&txn.UserID,
+			&txn.Kind,
+			&txn.Amount,
+			&txn.Balance,
+			&txn.Reference,
+			&txn.ActorID,
+			&txn.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, &txn)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// Reconcile returns the users whose cached users.coin is not the sum of their
+// wallet entries in the ledger.
+func (c *CoinsStore) Reconcile(ctx context.Context) ([]*CoinMismatch, error) {
+	query := `
+		SELECT u.id, u.coin, COALESCE(l.balance, 0)
+		FROM users u
+		LEFT JOIN (
+			SELECT user_id, SUM(amount)::bigint AS balance
+			FROM coin_entries
+			WHERE account = 'wallet'
+			GROUP BY user_id
+		) l ON l.user_id = u.id
+		WHERE u.coin <> COALESCE(l.balance, 0)
+		ORDER BY u.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := c.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mismatches := []*CoinMismatch{}
+	for rows.Next() {
+		var mismatch CoinMismatch
+		if err := rows.Scan(&mismatch.UserID, &mismatch.Balance, &mismatch.LedgerBalance); err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, &mismatch)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return mismatches, nil
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlfanDutaPamungkas/Govel/internal/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// TestParallelPurchasesCannotOverdraw runs against a migrated database named
+// by TEST_DB_ADDR and is skipped without one.
+func TestParallelPurchasesCannotOverdraw(t *testing.T) {
+	addr := os.Getenv("TEST_DB_ADDR")
+	if addr == "" {
+		t.Skip("TEST_DB_ADDR is not set")
+	}
+
+	pool, err := db.New(addr, 30, "15m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	ctx := context.Background()
+	coins := &CoinsStore{db: pool}
+
+	name := fmt.Sprintf("coins%d", time.Now().UnixNano())
+	var userID int64
+	err = pool.QueryRow(
+		ctx,
+		`INSERT INTO users (username, password, email) VALUES ($1, $2, $3) RETURNING id`,
+		name,
+		[]byte("x"),
+		name+"@example.com",
+	).Scan(&userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		balance   = 100
+		price     = 10
+		purchases = 20
+	)
+
+	err = withTx(pool, ctx, func(tx pgx.Tx) error {
+		return coins.record(ctx, tx, &CoinTransaction{UserID: userID, Kind: CoinAdminGrant, Amount: balance})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		bought     int
+		refused    int
+		unexpected []error
+	)
+
+	for i := 0; i < purchases; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			err := withTx(pool, ctx, func(tx pgx.Tx) error {
+				return coins.record(ctx, tx, &CoinTransaction{
+					UserID:    userID,
+					Kind:      CoinChapterPurchase,
+					Amount:    -price,
+					Reference: fmt.Sprintf("test:%d", i),
+				})
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case err == nil:
+				bought++
+			case errors.Is(err, ErrInsufficientCoin):
+				refused++
+			default:
+				unexpected = append(unexpected, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range unexpected {
+		t.Errorf("purchase failed: %v", err)
+	}
+
+	if want := balance / price; bought != want || refused != purchases-want {
+		t.Errorf("got %d purchases and %d refusals, want %d and %d", bought, refused, want, purchases-want)
+	}
+
+	var coin, ledger int64
+	err = pool.QueryRow(
+		ctx,
+		`SELECT u.coin, COALESCE(SUM(e.amount), 0)
+		FROM users u
+		LEFT JOIN coin_entries e ON e.user_id = u.id AND e.account = 'wallet'
+		WHERE u.id = $1
+		GROUP BY u.coin`,
+		userID,
+	).Scan(&coin, &ledger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if coin != 0 || ledger != 0 {
+		t.Errorf("got balance %d and ledger %d, want both 0", coin, ledger)
+	}
+}
@@ -18,25 +18,23 @@ type GenresStore struct {
 }
 
 func (g *GenresStore) Create(ctx context.Context, genre *Genre) error {
-	query := `
-		INSERT INTO genres (name)
-		VALUES ($1) RETURNING id
-	`
-
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
-
-	err := g.db.QueryRow(
-		ctx,
-		query,
-		genre.Name,
-	).Scan(&genre.ID)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return withTx(g.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditGenreCreate, "genre", &genre.ID, func() error {
+			query := `
+				INSERT INTO genres (name)
+				VALUES ($1) RETURNING id
+			`
+
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+			defer cancel()
+
+			return tx.QueryRow(
+				ctx,
+				query,
+				genre.Name,
+			).Scan(&genre.ID)
+		})
+	})
 }
 
 func (g *GenresStore) GetAllGenre(ctx context.Context) ([]*Genre, error) {
@@ -111,6 +109,14 @@ func (g *GenresStore) GetByID(ctx context.Context, genreID int32) (*Genre, error
 }
 
 func (g *GenresStore) Update(ctx context.Context, genre *Genre) error {
+	return withTx(g.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditGenreUpdate, "genre", genre.ID, func() error {
+			return g.update(ctx, tx, genre)
+		})
+	})
+}
+
+func (g *GenresStore) update(ctx context.Context, tx pgx.Tx, genre *Genre) error {
 	query := `
 		update genres
 		SET name = $1
@@ -121,7 +127,7 @@ func (g *GenresStore) Update(ctx context.Context, genre *Genre) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := g.db.QueryRow(
+	err := tx.QueryRow(
 		ctx,
 		query,
 		genre.Name,
@@ -144,21 +150,25 @@ func (g *GenresStore) Update(ctx context.Context, genre *Genre) error {
 }
 
 func (g *GenresStore) Delete(ctx context.Context, genreID int32) error {
-	query := `DELETE FROM genres WHERE id = $1`
+	return withTx(g.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditGenreDelete, "genre", genreID, func() error {
+			query := `DELETE FROM genres WHERE id = $1`
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+			defer cancel()
 
-	cmdTag, err := g.db.Exec(ctx, query, genreID)
-	if err != nil {
-		return err
-	}
+			cmdTag, err := tx.Exec(ctx, query, genreID)
+			if err != nil {
+				return err
+			}
 
-	if cmdTag.RowsAffected() == 0 {
-		return ErrNotFound
-	}
+			if cmdTag.RowsAffected() == 0 {
+				return ErrNotFound
+			}
 
-	return nil
+			return nil
+		})
+	})
 }
 
 func (g *GenresStore) GetGenresFromNovelID(ctx context.Context, novelID int64) ([]*Genre, error) {
@@ -46,3 +46,47 @@ func (h *HistoriesStore) Create(ctx context.Context, history *History) error {
 
 	return nil
 }
+
+func (h *HistoriesStore) GetByUserID(ctx context.Context, userID int64) ([]*History, error) {
+	query := `
+		SELECT id, user_id, chapter_slug, is_read, created_at, updated_at
+		FROM history
+		WHERE user_id = $1
+		ORDER BY updated_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := h.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	histories := []*History{}
+	for rows.Next() {
+		var history History
+		err := rows.Scan(
+			&history.ID,
+			&history.UserID,
+			&history.ChapterSlug,
+			&history.IsRead,
+			&history.CreatedAt,
+			&history.UpdatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		histories = append(histories, &history)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return histories, nil
+}
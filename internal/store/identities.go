@@ -0,0 +1,304 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrIdentityAlreadyLinked = errors.New("this account is already linked to another login for that provider")
+
+type Identity struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"-"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OIDCState struct {
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+}
+
+type IdentitiesStore struct {
+	db    *pgxpool.Pool
+	users *UsersStore
+}
+
+func (s *IdentitiesStore) CreateState(ctx context.Context, state string, oidcState *OIDCState, exp time.Duration) error {
+	query := `
+		INSERT INTO oidc_states (state, provider, nonce, code_verifier, expiry)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.Exec(
+		ctx,
+		query,
+		state,
+		oidcState.Provider,
+		oidcState.Nonce,
+		oidcState.CodeVerifier,
+		time.Now().Add(exp),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ConsumeState deletes and returns the login state so a callback can only be
+// completed once.
+func (s *IdentitiesStore) ConsumeState(ctx context.Context, state string) (*OIDCState, error) {
+	query := `
+		DELETE FROM oidc_states
+		WHERE state = $1 AND expiry > $2
+		RETURNING provider, nonce, code_verifier
+	`
+
+	hash := sha256.Sum256([]byte(state))
+	hashState := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var oidcState OIDCState
+	err := s.db.QueryRow(ctx, query, hashState, time.Now()).Scan(
+		&oidcState.Provider,
+		&oidcState.Nonce,
+		&oidcState.CodeVerifier,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &oidcState, nil
+}
+
+func (s *IdentitiesStore) GetByProviderSubject(ctx context.Context, provider, subject string) (*Identity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var identity Identity
+	err := s.db.QueryRow(ctx, query, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &identity, nil
+}
+
+func (s *IdentitiesStore) GetByUserID(ctx context.Context, userID int64) ([]*Identity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []*Identity{}
+	for rows.Next() {
+		var identity Identity
+		err := rows.Scan(
+			&identity.ID,
+			&identity.UserID,
+			&identity.Provider,
+			&identity.Subject,
+			&identity.Email,
+			&identity.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, &identity)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+// LinkByEmail attaches identity to the user owning its email. The provider
+// has verified that email, so a pending account is activated as well.
+func (s *IdentitiesStore) LinkByEmail(ctx context.Context, identity *Identity) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		user, err := s.getUserByEmailForUpdate(ctx, tx, identity.Email)
+		if err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+		if err := s.create(ctx, tx, identity); err != nil {
+			return err
+		}
+
+		if !user.IsActive {
+			user.IsActive = true
+			if err := s.users.changeIsActive(ctx, tx, user); err != nil {
+				return err
+			}
+
+			if err := s.users.deleteUserInvitations(ctx, tx, user.ID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// CreateWithUser registers a new user for identity. With an empty
+// invitation token the user is active straight away, otherwise the usual
+// activation email has to be confirmed first.
+func (s *IdentitiesStore) CreateWithUser(ctx context.Context, user *User, identity *Identity, token string, invitationExp time.Duration) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		if err := s.users.Create(ctx, tx, user); err != nil {
+			return err
+		}
+
+		if token == "" {
+			user.IsActive = true
+			if err := s.users.changeIsActive(ctx, tx, user); err != nil {
+				return err
+			}
+		} else {
+			if err := s.users.createUserInvitation(ctx, tx, token, user.ID, invitationExp); err != nil {
+				return err
+			}
+		}
+
+		identity.UserID = user.ID
+		return s.create(ctx, tx, identity)
+	})
+}
+
+func (s *IdentitiesStore) Delete(ctx context.Context, userID int64, provider string) error {
+	query := `
+		DELETE FROM user_identities WHERE user_id = $1 AND provider = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	cmdTag, err := s.db.Exec(ctx, query, userID, provider)
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *IdentitiesStore) create(ctx context.Context, tx pgx.Tx, identity *Identity) error {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRow(
+		ctx,
+		query,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+	).Scan(&identity.ID, &identity.CreatedAt)
+
+	if err != nil {
+		switch {
+		case err.Error() == `ERROR: duplicate key value violates unique constraint "user_identities_provider_subject_key" (SQLSTATE 23505)`:
+			return ErrConflict
+		case err.Error() == `ERROR: duplicate key value violates unique constraint "user_identities_user_id_provider_key" (SQLSTATE 23505)`:
+			return ErrIdentityAlreadyLinked
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *IdentitiesStore) getUserByEmailForUpdate(ctx context.Context, tx pgx.Tx, email string) (*User, error) {
+	query := `
+		SELECT id, username, email, is_active
+		FROM users
+		WHERE email = $1
+		FOR UPDATE
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var user User
+	err := tx.QueryRow(ctx, query, email).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.IsActive,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
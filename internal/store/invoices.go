@@ -9,6 +9,23 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// Invoice statuses reported by the payment provider.
+const (
+	InvoicePending = "PENDING"
+	InvoicePaid    = "PAID"
+	InvoiceSettled = "SETTLED"
+	InvoiceExpired = "EXPIRED"
+	InvoiceFailed  = "FAILED"
+)
+
+var InvoiceStatuses = []string{InvoicePending, InvoicePaid, InvoiceSettled, InvoiceExpired, InvoiceFailed}
+
+// IsInvoicePaid reports whether status means the payment went through. A
+// settled invoice is a paid one whose funds have reached the merchant.
+func IsInvoicePaid(status string) bool {
+	return status == InvoicePaid || status == InvoiceSettled
+}
+
 type Invoice struct {
 	ID         int64     `json:"id"`
 	UserID     int64     `json:"user_id"`
@@ -17,7 +34,10 @@ type Invoice struct {
 	InvoiceURL string    `json:"invoice_url"`
 	Status     string    `json:"status"`
 	Amount     float64   `json:"amount"`
+	Currency   string    `json:"currency"`
 	Plan       string    `json:"plan"`
+	Coins      int64     `json:"coins"`
+	BonusCoins int64     `json:"bonus_coins"`
 	CreatedAt  time.Time `json:"created_at"`
 	User       User      `json:"user"`
 }
@@ -28,8 +48,8 @@ type InvoicesStore struct {
 
 func (i *InvoicesStore) Create(ctx context.Context, invoice *Invoice) error {
 	query := `
-		INSERT INTO invoices (user_id, external_id, invoice_id, invoice_url, status, amount, plan)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO invoices (user_id, external_id, invoice_id, invoice_url, status, amount, currency, plan, coins, bonus_coins)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id, created_at
 	`
 
@@ -45,7 +65,10 @@ func (i *InvoicesStore) Create(ctx context.Context, invoice *Invoice) error {
 		invoice.InvoiceURL,
 		invoice.Status,
 		invoice.Amount,
+		invoice.Currency,
 		invoice.Plan,
+		invoice.Coins,
+		invoice.BonusCoins,
 	).Scan(&invoice.ID, &invoice.CreatedAt)
 
 	if err != nil {
@@ -79,9 +102,49 @@ func (i *InvoicesStore) update(ctx context.Context, tx pgx.Tx, invoice *Invoice)
 	return nil
 }
 
+func (i *InvoicesStore) lockStatus(ctx context.Context, tx pgx.Tx, invoiceID string) (string, error) {
+	query := `SELECT status FROM invoices WHERE invoice_id = $1 FOR UPDATE`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var status string
+	err := tx.QueryRow(ctx, query, invoiceID).Scan(&status)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return "", ErrNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return status, nil
+}
+
+// anonymizeDeleted detaches the invoices of users soft deleted before cutoff
+// and drops the payment link, which can identify the payer.
+func (i *InvoicesStore) anonymizeDeleted(ctx context.Context, tx pgx.Tx, cutoff time.Time) error {
+	query := `
+		UPDATE invoices
+		SET user_id = NULL, invoice_url = ''
+		WHERE user_id IN (SELECT id FROM users WHERE deleted_at < $1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.Exec(ctx, query, cutoff)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (i *InvoicesStore) GetByInvoiceID(ctx context.Context, invoiceID string) (*Invoice, error) {
 	query := `
-		SELECT id, user_id, external_id, invoice_id, status, amount, plan, created_at
+		SELECT id, COALESCE(user_id, 0), external_id, invoice_id, status, amount, currency, plan, coins, bonus_coins, created_at
 		FROM invoices
 		WHERE invoice_id = $1
 	`
@@ -102,7 +165,10 @@ func (i *InvoicesStore) GetByInvoiceID(ctx context.Context, invoiceID string) (*
 		&invoice.InvoiceID,
 		&invoice.Status,
 		&invoice.Amount,
+		&invoice.Currency,
 		&invoice.Plan,
+		&invoice.Coins,
+		&invoice.BonusCoins,
 		&invoice.CreatedAt,
 	)
 
@@ -120,7 +186,7 @@ func (i *InvoicesStore) GetByInvoiceID(ctx context.Context, invoiceID string) (*
 
 func (i *InvoicesStore) GetByUserID(ctx context.Context, userID int64) ([]*Invoice, error) {
 	query := `
-		SELECT id, user_id, external_id, invoice_id, invoice_url, status, amount, plan, created_at
+		SELECT id, user_id, external_id, invoice_id, invoice_url, status, amount, currency, plan, coins, bonus_coins, created_at
 		FROM invoices
 		WHERE user_id = $1
 	`
@@ -152,7 +218,10 @@ func (i *InvoicesStore) GetByUserID(ctx context.Context, userID int64) ([]*Invoi
 			&invoice.InvoiceURL,
 			&invoice.Status,
 			&invoice.Amount,
+			&invoice.Currency,
 			&invoice.Plan,
+			&invoice.Coins,
+			&invoice.BonusCoins,
 			&invoice.CreatedAt,
 		)
 
@@ -173,8 +242,9 @@ func (i *InvoicesStore) GetByUserID(ctx context.Context, userID int64) ([]*Invoi
 func (i *InvoicesStore) GetAll(ctx context.Context) ([]*Invoice, error) {
 	query := `
 		SELECT 
-			i.id, i.user_id, i.external_id, i.invoice_id, i.status, i.amount, i.plan, i.created_at,
-			u.username
+			i.id, COALESCE(i.user_id, 0), i.external_id, i.invoice_id, i.status, i.amount, i.currency, i.plan,
+			i.coins, i.bonus_coins, i.created_at,
+			COALESCE(u.username, '')
 		FROM invoices as i
 		left join users as u on i.user_id = u.id;
 	`
@@ -203,7 +273,10 @@ func (i *InvoicesStore) GetAll(ctx context.Context) ([]*Invoice, error) {
 			&invoice.InvoiceID,
 			&invoice.Status,
 			&invoice.Amount,
+			&invoice.Currency,
 			&invoice.Plan,
+			&invoice.Coins,
+			&invoice.BonusCoins,
 			&invoice.CreatedAt,
 			&invoice.User.Username,
 		)
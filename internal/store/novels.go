@@ -1,9 +1,12 @@
 package store
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -12,17 +15,65 @@ import (
 
 var ErrInvalidOption = errors.New("invalid option")
 
+// Novel statuses. A scheduled novel goes live at PublishedAt. Hiatus and
+// completed novels stay listed and tell readers whether to expect updates.
+const (
+	NovelDraft     = "draft"
+	NovelScheduled = "scheduled"
+	NovelPublished = "published"
+	NovelHiatus    = "hiatus"
+	NovelCompleted = "completed"
+)
+
+var NovelStatuses = []string{NovelDraft, NovelScheduled, NovelPublished, NovelHiatus, NovelCompleted}
+
+var (
+	ErrInvalidStatusTransition = errors.New("the novel can't move to that status")
+	ErrInvalidPublishTime      = errors.New("scheduled novels need a future published_at and published ones a past one")
+)
+
+// novelTransitions lists the statuses each status may move to. Once readers
+// have seen a novel it can't go back to draft.
+var novelTransitions = map[string][]string{
+	NovelDraft:     {NovelScheduled, NovelPublished},
+	NovelScheduled: {NovelDraft, NovelScheduled, NovelPublished},
+	NovelPublished: {NovelHiatus, NovelCompleted},
+	NovelHiatus:    {NovelPublished, NovelCompleted},
+	NovelCompleted: {NovelPublished, NovelHiatus},
+}
+
+func CanTransitionNovel(from, to string) bool {
+	return slices.Contains(novelTransitions[from], to)
+}
+
+// novelStatusExpr is the status of novels n as readers see it: a scheduled
+// novel is published as soon as its time comes, before the sweeper stores it.
+const novelStatusExpr = `CASE WHEN n.status = 'scheduled' AND n.published_at <= now() THEN 'published' ELSE n.status END`
+
+// novelVisibleExpr matches the novels n readers may see.
+const novelVisibleExpr = `(n.status <> 'draft' AND n.published_at <= now())`
+
 type Novel struct {
-	ID         int64      `json:"id"`
-	Title      string     `json:"title"`
-	Author     string     `json:"author"`
-	Synopsis   string     `json:"synopsis"`
-	Genre      []*Genre   `json:"genre"`
-	ImageURL   string     `json:"image_url"`
-	Chapters   []*Chapter `json:"chapters"`
-	IsBookmark bool       `json:"is_bookmark"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
+	ID          int64      `json:"id"`
+	Title       string     `json:"title"`
+	Author      string     `json:"author"`
+	OwnerID     *int64     `json:"owner_id,omitempty"`
+	Synopsis    string     `json:"synopsis"`
+	Genre       []*Genre   `json:"genre"`
+	ImageURL    string     `json:"image_url"`
+	Language    string     `json:"language"`
+	Status      string     `json:"status"`
+	PublishedAt *time.Time `json:"published_at"`
+	Headline    string     `json:"headline,omitempty"`
+	Chapters    []*Chapter `json:"chapters"`
+	IsBookmark  bool       `json:"is_bookmark"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// IsPublic reports whether readers may see the novel.
+func (n *Novel) IsPublic() bool {
+	return n.Status != NovelDraft && n.Status != NovelScheduled
 }
 
 var ErrDuplicateNovelTitle = errors.New("a novel with that title already exist")
@@ -33,8 +84,8 @@ type NovelsStore struct {
 
 func (n *NovelsStore) Create(ctx context.Context, tx pgx.Tx, novel *Novel) error {
 	query := `
-		INSERT INTO novels (title, author, synopsis, image_url)
-		VALUES ($1, $2, $3, $4) RETURNING id, created_at
+		INSERT INTO novels (title, author, synopsis, image_url, owner_id, language)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, status, created_at
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -47,7 +98,9 @@ func (n *NovelsStore) Create(ctx context.Context, tx pgx.Tx, novel *Novel) error
 		novel.Author,
 		novel.Synopsis,
 		novel.ImageURL,
-	).Scan(&novel.ID, &novel.CreatedAt)
+		novel.OwnerID,
+		cmp.Or(novel.Language, DefaultSearchLanguage),
+	).Scan(&novel.ID, &novel.Status, &novel.CreatedAt)
 
 	if err != nil {
 		switch {
@@ -63,15 +116,13 @@ func (n *NovelsStore) Create(ctx context.Context, tx pgx.Tx, novel *Novel) error
 
 func (n *NovelsStore) CreateNovelAndInsertGenres(ctx context.Context, novel *Novel, genres []int32) error {
 	return withTx(n.db, ctx, func(tx pgx.Tx) error {
-		if err := n.Create(ctx, tx, novel); err != nil {
-			return err
-		}
+		return audited(ctx, tx, AuditNovelCreate, "novel", &novel.ID, func() error {
+			if err := n.Create(ctx, tx, novel); err != nil {
+				return err
+			}
 
-		if err := n.insertGenresToNovel(ctx, tx, novel.ID, genres); err != nil {
-			return err
-		}
-
-		return nil
+			return n.insertGenresToNovel(ctx, tx, novel.ID, genres)
+		})
 	})
 }
 
@@ -81,8 +132,12 @@ func (n *NovelsStore) GetByID(ctx context.Context, novelID int64, userID int64)
 			n.id, 
 			n.title, 
 			n.author, 
+			n.owner_id,
 			n.synopsis, 
 			n.image_url, 
+			n.language,
+			`+novelStatusExpr+`,
+			n.published_at,
 			n.created_at, 
 			n.updated_at,
 		EXISTS (
@@ -108,8 +163,12 @@ func (n *NovelsStore) GetByID(ctx context.Context, novelID int64, userID int64)
 		&novel.ID,
 		&novel.Title,
 		&novel.Author,
+		&novel.OwnerID,
 		&novel.Synopsis,
 		&novel.ImageURL,
+		&novel.Language,
+		&novel.Status,
+		&novel.PublishedAt,
 		&novel.CreatedAt,
 		&novel.UpdatedAt,
 		&novel.IsBookmark,
@@ -127,90 +186,174 @@ func (n *NovelsStore) GetByID(ctx context.Context, novelID int64, userID int64)
 	return &novel, err
 }
 
-func (n *NovelsStore) GetAllNovel(ctx context.Context, order string, search string) ([]*Novel, error) {
-	var query string
-	var args []interface{}
+// NovelFilter narrows and orders a novel listing. A novel must have every
+// genre in GenreIDs. Only public novels are listed unless IncludeUnpublished
+// is set. Sort defaults to relevance when searching and to updated_at
+// otherwise.
+type NovelFilter struct {
+	Search             string
+	InChapters         bool
+	Author             string
+	GenreIDs           []int32
+	Status             string
+	IncludeUnpublished bool
+	Sort               string
+	Order              string
+	Cursor             string
+	Limit              int
+	IncludeTotal       bool
+}
 
-	query = `
-		SELECT id, title, author, synopsis, image_url, created_at, updated_at
-		FROM novels
-	`
+// novelSortKeys are the orderings a novel listing accepts. Popularity is the
+// number of readers who bookmarked the novel.
+var novelSortKeys = map[string]sortKey{
+	"updated_at": {"n.updated_at", true, newTimeValue},
+	"created_at": {"n.created_at", true, newTimeValue},
+	"title":      {"n.title", false, newStringValue},
+	"popularity": {"n.bookmark_count", true, newIntValue},
+	"chapters":   {"n.chapter_count", true, newIntValue},
+}
 
-	if search != "" {
-		query += ` WHERE title_fts @@ plainto_tsquery('english', $1)`
-		args = append(args, search)
+// conditions returns the filter as conditions on novels n, numbering its
+// placeholders after args. search is nil unless the filter has a search.
+func (f NovelFilter) conditions(args []any) ([]string, []any, *novelSearch) {
+	var (
+		conditions []string
+		search     *novelSearch
+	)
+
+	if !f.IncludeUnpublished {
+		conditions = append(conditions, novelVisibleExpr)
 	}
 
-	if order == "updated_at" {
-		query += fmt.Sprintf(" ORDER BY %s DESC", order)
-		query += " LIMIT 10"
-	} else if order == "created_at" {
-		query += fmt.Sprintf(" ORDER BY %s DESC", order)
-		query += " LIMIT 4"
-	} else if order != "" {
-		return nil, ErrInvalidOption
+	if f.Status != "" {
+		args = append(args, f.Status)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", novelStatusExpr, len(args)))
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+	if f.Search != "" {
+		search, args = newNovelSearch(f.Search, f.InChapters, args)
+		conditions = append(conditions, search.condition)
+	}
+
+	if f.Author != "" {
+		args = append(args, f.Author)
+		conditions = append(conditions, fmt.Sprintf("lower(n.author) = lower($%d)", len(args)))
+	}
 
-	rows, err := n.db.Query(ctx, query, args...)
+	if len(f.GenreIDs) > 0 {
+		genreIDs := slices.Clone(f.GenreIDs)
+		slices.Sort(genreIDs)
+		genreIDs = slices.Compact(genreIDs)
+
+		args = append(args, genreIDs, len(genreIDs))
+		conditions = append(conditions, fmt.Sprintf(`n.id IN (
+			SELECT novel_id FROM novel_genres
+			WHERE genre_id = ANY($%d)
+			GROUP BY novel_id
+			HAVING COUNT(*) = $%d
+		)`, len(args)-1, len(args)))
+	}
+
+	return conditions, args, search
+}
+
+// keyset resolves the filter's ordering from keys, adding relevance when
+// searching.
+func (f NovelFilter) keyset(keys map[string]sortKey, defaultSort string, search *novelSearch) (*keyset, error) {
+	sort := f.Sort
+
+	if search != nil {
+		keys = maps.Clone(keys)
+		keys["relevance"] = sortKey{search.rank, true, newFloatValue}
+		sort = cmp.Or(sort, "relevance")
+	}
+
+	return newKeyset(keys, cmp.Or(sort, defaultSort), f.Order, f.Cursor)
+}
+
+func (n *NovelsStore) List(ctx context.Context, filter NovelFilter) (*Page[*Novel], error) {
+	conditions, args, search := filter.conditions(nil)
+
+	ks, err := filter.keyset(novelSortKeys, "updated_at", search)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var novels []*Novel
-	for rows.Next() {
+	headline := "''"
+	if search != nil {
+		headline = search.headline
+	}
+
+	listed := listing{
+		columns: `n.id, n.title, n.author, n.owner_id, n.synopsis, n.image_url, n.language,
+			` + novelStatusExpr + `, n.published_at, n.created_at, n.updated_at, ` + headline,
+		from:       "novels n",
+		conditions: conditions,
+		args:       args,
+		id:         "n.id",
+		withTotal:  filter.IncludeTotal,
+	}
+
+	page, err := listPage(ctx, n.db, ks, listed, filter.Limit, func() (*Novel, []any) {
 		var novel Novel
-		err := rows.Scan(
+		return &novel, []any{
 			&novel.ID,
 			&novel.Title,
 			&novel.Author,
+			&novel.OwnerID,
 			&novel.Synopsis,
 			&novel.ImageURL,
+			&novel.Language,
+			&novel.Status,
+			&novel.PublishedAt,
 			&novel.CreatedAt,
 			&novel.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
+			&novel.Headline,
 		}
-		novels = append(novels, &novel)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+	for _, novel := range page.Items {
+		novel.Headline = escapeHeadline(novel.Headline)
 	}
 
-	return novels, nil
+	return page, nil
 }
 
-func (n *NovelsStore) GetNovelsFromGenreID(ctx context.Context, genreID int32) ([]*Novel, error) {
+func (n *NovelsStore) GetByOwnerID(ctx context.Context, ownerID int64) ([]*Novel, error) {
 	query := `
-		SELECT n.id, n.title, n.author, n.synopsis, n.image_url, n.created_at, n.updated_at
-		FROM novel_genres ng
-		LEFT JOIN novels n on n.id = ng.novel_id
-		WHERE ng.genre_id = $1
+		SELECT n.id, n.title, n.author, n.owner_id, n.synopsis, n.image_url, n.language,
+			`+novelStatusExpr+`, n.published_at, n.created_at, n.updated_at
+		FROM novels n
+		WHERE n.owner_id = $1
+		ORDER BY n.updated_at DESC
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := n.db.Query(ctx, query, genreID)
+	rows, err := n.db.Query(ctx, query, ownerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var novels []*Novel
+	novels := []*Novel{}
 	for rows.Next() {
 		var novel Novel
 		err := rows.Scan(
 			&novel.ID,
 			&novel.Title,
 			&novel.Author,
+			&novel.OwnerID,
 			&novel.Synopsis,
 			&novel.ImageURL,
+			&novel.Language,
+			&novel.Status,
+			&novel.PublishedAt,
 			&novel.CreatedAt,
 			&novel.UpdatedAt,
 		)
@@ -227,32 +370,137 @@ func (n *NovelsStore) GetNovelsFromGenreID(ctx context.Context, genreID int32) (
 	return novels, nil
 }
 
+// SetStatus moves novel to status. publishedAt must be in the future when
+// scheduling; when publishing for the first time it may backdate the novel
+// and defaults to now.
+func (n *NovelsStore) SetStatus(ctx context.Context, novel *Novel, status string, publishedAt *time.Time) error {
+	now := time.Now()
+
+	switch {
+	case status == NovelScheduled && (publishedAt == nil || !publishedAt.After(now)):
+		return ErrInvalidPublishTime
+	case status != NovelScheduled && publishedAt != nil && publishedAt.After(now):
+		return ErrInvalidPublishTime
+	}
+
+	return withTx(n.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditNovelStatus, "novel", novel.ID, func() error {
+			current, err := n.lockStatus(ctx, tx, novel.ID)
+			if err != nil {
+				return err
+			}
+
+			if !CanTransitionNovel(current, status) {
+				return ErrInvalidStatusTransition
+			}
+
+			return n.setStatus(ctx, tx, novel, status, publishedAt)
+		})
+	})
+}
+
+func (n *NovelsStore) lockStatus(ctx context.Context, tx pgx.Tx, novelID int64) (string, error) {
+	query := `SELECT ` + novelStatusExpr + ` FROM novels n WHERE n.id = $1 FOR UPDATE`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var status string
+	err := tx.QueryRow(ctx, query, novelID).Scan(&status)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return "", ErrNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return status, nil
+}
+
+func (n *NovelsStore) setStatus(ctx context.Context, tx pgx.Tx, novel *Novel, status string, publishedAt *time.Time) error {
+	query := `
+		UPDATE novels n
+		SET status = $1,
+			published_at = CASE
+				WHEN $1 = 'draft' THEN NULL
+				WHEN n.status IN ('draft', 'scheduled') THEN COALESCE($2, now())
+				ELSE n.published_at
+			END
+		WHERE n.id = $3
+		RETURNING ` + novelStatusExpr + `, n.published_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return tx.QueryRow(
+		ctx,
+		query,
+		status,
+		publishedAt,
+		novel.ID,
+	).Scan(&novel.Status, &novel.PublishedAt)
+}
+
+// PublishScheduled stores the published status of scheduled novels whose time
+// has come. Readers already see them as published.
+func (n *NovelsStore) PublishScheduled(ctx context.Context) (int64, error) {
+	query := `
+		UPDATE novels
+		SET status = 'published'
+		WHERE status = 'scheduled' AND published_at <= now()
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := n.db.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
 func (n *NovelsStore) Update(ctx context.Context, novel *Novel) error {
+	return withTx(n.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditNovelUpdate, "novel", novel.ID, func() error {
+			return n.update(ctx, tx, novel)
+		})
+	})
+}
+
+func (n *NovelsStore) update(ctx context.Context, tx pgx.Tx, novel *Novel) error {
 	query := `
 		update novels
-		SET title = $1, author = $2, synopsis = $3, image_url = $4, updated_at = $5
-		WHERE id = $6
-		RETURNING id, title, author, synopsis, image_url, created_at, updated_at
+		SET title = $1, author = $2, synopsis = $3, image_url = $4, language = $5, updated_at = $6
+		WHERE id = $7
+		RETURNING id, title, author, owner_id, synopsis, image_url, language, created_at, updated_at
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := n.db.QueryRow(
+	err := tx.QueryRow(
 		ctx,
 		query,
 		novel.Title,
 		novel.Author,
 		novel.Synopsis,
 		novel.ImageURL,
+		cmp.Or(novel.Language, DefaultSearchLanguage),
 		novel.UpdatedAt,
 		novel.ID,
 	).Scan(
 		&novel.ID,
 		&novel.Title,
 		&novel.Author,
+		&novel.OwnerID,
 		&novel.Synopsis,
 		&novel.ImageURL,
+		&novel.Language,
 		&novel.CreatedAt,
 		&novel.UpdatedAt,
 	)
@@ -270,21 +518,25 @@ func (n *NovelsStore) Update(ctx context.Context, novel *Novel) error {
 }
 
 func (n *NovelsStore) Delete(ctx context.Context, novelID int64) error {
-	query := `DELETE FROM novels WHERE id = $1`
+	return withTx(n.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditNovelDelete, "novel", novelID, func() error {
+			query := `DELETE FROM novels WHERE id = $1`
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+			defer cancel()
 
-	cmdTag, err := n.db.Exec(ctx, query, novelID)
-	if err != nil {
-		return err
-	}
+			cmdTag, err := tx.Exec(ctx, query, novelID)
+			if err != nil {
+				return err
+			}
 
-	if cmdTag.RowsAffected() == 0 {
-		return ErrNotFound
-	}
+			if cmdTag.RowsAffected() == 0 {
+				return ErrNotFound
+			}
 
-	return nil
+			return nil
+		})
+	})
 }
 
 func (n *NovelsStore) UpdateNovelGenres(ctx context.Context, novelID int64, genres []int32) error {
@@ -0,0 +1,267 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// Page is one page of a keyset paginated listing. NextCursor is empty on the
+// last page. Total counts every item matching the filter and is only set when
+// asked for, as counting costs a scan of every match.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor"`
+	Total      *int64 `json:"total,omitempty"`
+}
+
+// listing is a query paged by listPage: columns selected from from, which may
+// join other tables, on the rows matching conditions. id is the unique column
+// breaking ties between rows with the same sort value.
+type listing struct {
+	columns    string
+	from       string
+	conditions []string
+	args       []any
+	id         string
+	withTotal  bool
+}
+
+// sortKey is a column a listing can be ordered by. The keyset is matched and
+// ordered on expr together with the listing's id, so expr should lead an
+// index ending in the id. newValue returns a pointer to scan the column into,
+// which also decodes it back out of a cursor.
+type sortKey struct {
+	expr     string
+	desc     bool
+	newValue func() any
+}
+
+func newTimeValue() any   { return new(time.Time) }
+func newStringValue() any { return new(string) }
+func newIntValue() any    { return new(int64) }
+func newFloatValue() any  { return new(float64) }
+
+// cursor is the position after the last item of a page. Sort and Order are
+// kept so a cursor can't be replayed against a different ordering.
+type cursor struct {
+	Sort  string          `json:"s"`
+	Order string          `json:"o"`
+	Value json.RawMessage `json:"v"`
+	ID    int64           `json:"id"`
+}
+
+// keyset is a resolved ordering plus where the requested page starts.
+type keyset struct {
+	sort  string
+	key   sortKey
+	desc  bool
+	after any
+	id    int64
+}
+
+func newKeyset(keys map[string]sortKey, sort, order, rawCursor string) (*keyset, error) {
+	key, ok := keys[sort]
+	if !ok {
+		return nil, ErrInvalidOption
+	}
+
+	k := &keyset{sort: sort, key: key, desc: key.desc}
+
+	switch order {
+	case "":
+	case "asc":
+		k.desc = false
+	case "desc":
+		k.desc = true
+	default:
+		return nil, ErrInvalidOption
+	}
+
+	if rawCursor == "" {
+		return k, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(rawCursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if c.Sort != k.sort || c.Order != k.order() {
+		return nil, ErrInvalidCursor
+	}
+
+	value := key.newValue()
+	if err := json.Unmarshal(c.Value, value); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	k.after = value
+	k.id = c.ID
+
+	return k, nil
+}
+
+func (k *keyset) order() string {
+	if k.desc {
+		return "desc"
+	}
+	return "asc"
+}
+
+// where returns the condition that skips every row up to and including the
+// cursor, with id breaking ties and placeholders numbered from argN. It is
+// empty on the first page.
+func (k *keyset) where(id string, argN int) (string, []any) {
+	if k.after == nil {
+		return "", nil
+	}
+
+	op := ">"
+	if k.desc {
+		op = "<"
+	}
+
+	return fmt.Sprintf("(%s, %s) %s ($%d, $%d)", k.key.expr, id, op, argN, argN+1), []any{k.after, k.id}
+}
+
+func (k *keyset) orderBy(id string) string {
+	return fmt.Sprintf("ORDER BY %[1]s %[3]s, %[2]s %[3]s", k.key.expr, id, k.order())
+}
+
+// next encodes the cursor for the page after the row with value and id.
+func (k *keyset) next(value any, id int64) (string, error) {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := json.Marshal(cursor{Sort: k.sort, Order: k.order(), Value: v, ID: id})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// pageLimit clamps limit to 1..MaxPageLimit, using the default when unset.
+func pageLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return DefaultPageLimit
+	case limit > MaxPageLimit:
+		return MaxPageLimit
+	default:
+		return limit
+	}
+}
+
+// listPage returns the page of l that ks points at. columns returns a new item
+// and the destinations l's columns scan into.
+func listPage[T any](ctx context.Context, db *pgxpool.Pool, ks *keyset, l listing, limit int, columns func() (T, []any)) (*Page[T], error) {
+	limit = pageLimit(limit)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	page := &Page[T]{Items: []T{}}
+
+	if l.withTotal {
+		page.Total = new(int64)
+
+		err := db.QueryRow(ctx, `SELECT COUNT(*) FROM `+l.from+whereAll(l.conditions), l.args...).Scan(page.Total)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conditions := l.conditions
+	args := l.args
+
+	after, afterArgs := ks.where(l.id, len(args)+1)
+	if after != "" {
+		conditions = append(slices.Clip(conditions), after)
+		args = append(slices.Clip(args), afterArgs...)
+	}
+	args = append(slices.Clip(args), limit+1)
+
+	query := fmt.Sprintf(
+		`SELECT %s, %s, %s FROM %s%s %s LIMIT $%d`,
+		l.columns,
+		ks.key.expr,
+		l.id,
+		l.from,
+		whereAll(conditions),
+		ks.orderBy(l.id),
+		len(args),
+	)
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		more      bool
+		lastValue any
+		lastID    int64
+	)
+
+	for rows.Next() {
+		if len(page.Items) == limit {
+			more = true
+			break
+		}
+
+		item, dest := columns()
+		value := ks.key.newValue()
+		var id int64
+
+		if err := rows.Scan(append(dest, value, &id)...); err != nil {
+			return nil, err
+		}
+
+		page.Items = append(page.Items, item)
+		lastValue, lastID = value, id
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if more {
+		page.NextCursor, err = ks.next(lastValue, lastID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+func whereAll(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnknownPasswordHash = errors.New("unknown password hash format")
+
+// PasswordHasher produces the hashes stored in users.password. Every hash
+// starts with an algorithm and parameter prefix so old hashes keep verifying
+// after the default changes.
+type PasswordHasher interface {
+	Hash(text string) ([]byte, error)
+	// Current reports whether hash was made by this hasher with its current
+	// parameters.
+	Current(hash []byte) bool
+}
+
+// DefaultPasswordHasher hashes new passwords. Hashes made with anything else
+// are upgraded on the next successful login.
+var DefaultPasswordHasher PasswordHasher = NewArgon2idHasher(64*1024, 3, 2)
+
+var argon2idPrefix = []byte("$argon2id$")
+
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  int
+	KeyLength   uint32
+}
+
+// NewArgon2idHasher returns a hasher with memory in KiB and a 16 byte salt
+// and 32 byte key.
+func NewArgon2idHasher(memory, iterations uint32, parallelism uint8) *Argon2idHasher {
+	return &Argon2idHasher{
+		Memory:      memory,
+		Iterations:  iterations,
+		Parallelism: parallelism,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// Hash returns the PHC string format, e.g.
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func (h *Argon2idHasher) Hash(text string) ([]byte, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(text), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+
+	return []byte(fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.Memory,
+		h.Iterations,
+		h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)), nil
+}
+
+func (h *Argon2idHasher) Current(hash []byte) bool {
+	params, _, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false
+	}
+
+	return params.Memory == h.Memory &&
+		params.Iterations == h.Iterations &&
+		params.Parallelism == h.Parallelism &&
+		len(key) == int(h.KeyLength)
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{Cost: cost}
+}
+
+func (h *BcryptHasher) Hash(text string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(text), h.Cost)
+}
+
+func (h *BcryptHasher) Current(hash []byte) bool {
+	cost, err := bcrypt.Cost(hash)
+	return err == nil && cost == h.Cost
+}
+
+// verifyPasswordHash checks text against hash using whichever algorithm the
+// hash prefix names.
+func verifyPasswordHash(hash []byte, text string) (bool, error) {
+	switch {
+	case bytes.HasPrefix(hash, argon2idPrefix):
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, err
+		}
+
+		other := argon2.IDKey([]byte(text), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	case bytes.HasPrefix(hash, []byte("$2")):
+		err := bcrypt.CompareHashAndPassword(hash, []byte(text))
+		switch {
+		case err == nil:
+			return true, nil
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, nil
+		default:
+			return false, err
+		}
+	default:
+		return false, ErrUnknownPasswordHash
+	}
+}
+
+func decodeArgon2id(hash []byte) (*Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrUnknownPasswordHash
+	}
+
+	var params Argon2idHasher
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, ErrUnknownPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrUnknownPasswordHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrUnknownPasswordHash
+	}
+
+	params.SaltLength = len(salt)
+	params.KeyLength = uint32(len(key))
+
+	return &params, salt, key, nil
+}
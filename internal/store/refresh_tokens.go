@@ -0,0 +1,315 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+
+type RefreshToken struct {
+	ID           int64      `json:"id"`
+	UserID       int64      `json:"user_id"`
+	FamilyID     string     `json:"family_id"`
+	SessionID    int64      `json:"session_id"`
+	TokenVersion int64      `json:"-"`
+	Expiry       time.Time  `json:"expiry"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type RefreshTokensStore struct {
+	db *pgxpool.Pool
+}
+
+func (s *RefreshTokensStore) create(ctx context.Context, tx pgx.Tx, token *RefreshToken, hashToken string) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, token, family_id, session_id, token_version, expiry)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRow(
+		ctx,
+		query,
+		token.UserID,
+		hashToken,
+		token.FamilyID,
+		token.SessionID,
+		token.TokenVersion,
+		token.Expiry,
+	).Scan(&token.ID, &token.CreatedAt)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Rotate revokes the refresh token identified by plainToken and issues its
+// successor in the same family. Presenting a token that was already rotated
+// on a live session revokes the whole family and bumps the owner's token
+// version.
+func (s *RefreshTokensStore) Rotate(ctx context.Context, plainToken string, newHashToken string, exp time.Duration) (*RefreshToken, error) {
+	var (
+		next   *RefreshToken
+		reused *RefreshToken
+	)
+
+	err := withTx(s.db, ctx, func(tx pgx.Tx) error {
+		current, userTokenVersion, sessionRevoked, err := s.getForUpdate(ctx, tx, plainToken)
+		if err != nil {
+			return err
+		}
+
+		// a signed-out device replaying its last token is not an attack
+		if sessionRevoked {
+			return ErrNotFound
+		}
+
+		if current.RevokedAt != nil {
+			reused = current
+			return nil
+		}
+
+		if current.Expiry.Before(time.Now()) || current.TokenVersion != userTokenVersion {
+			return ErrNotFound
+		}
+
+		if err := s.revoke(ctx, tx, current.ID); err != nil {
+			return err
+		}
+
+		next = &RefreshToken{
+			UserID:       current.UserID,
+			FamilyID:     current.FamilyID,
+			SessionID:    current.SessionID,
+			TokenVersion: userTokenVersion,
+			Expiry:       time.Now().Add(exp),
+		}
+
+		return s.create(ctx, tx, next, newHashToken)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if reused != nil {
+		if err := s.RevokeFamily(ctx, reused.UserID, reused.FamilyID, true); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrRefreshTokenReused
+	}
+
+	return next, nil
+}
+
+// RevokeFamily revokes every token descending from the same login. When
+// bumpTokenVersion is set the user's access tokens are invalidated as well.
+func (s *RefreshTokensStore) RevokeFamily(ctx context.Context, userID int64, familyID string, bumpTokenVersion bool) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		if err := s.revokeFamily(ctx, tx, familyID); err != nil {
+			return err
+		}
+
+		if bumpTokenVersion {
+			if err := bumpUserTokenVersion(ctx, tx, userID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *RefreshTokensStore) GetByToken(ctx context.Context, plainToken string) (*RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, session_id, token_version, expiry, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token = $1
+	`
+
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var token RefreshToken
+	err := s.db.QueryRow(ctx, query, hashToken).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.SessionID,
+		&token.TokenVersion,
+		&token.Expiry,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &token, nil
+}
+
+func (s *RefreshTokensStore) getForUpdate(ctx context.Context, tx pgx.Tx, plainToken string) (*RefreshToken, int64, bool, error) {
+	query := `
+		SELECT rt.id, rt.user_id, rt.family_id, rt.session_id, rt.token_version, rt.expiry, rt.revoked_at, rt.created_at,
+			u.token_version, s.revoked_at IS NOT NULL
+		FROM refresh_tokens rt
+		JOIN users u ON u.id = rt.user_id
+		JOIN sessions s ON s.id = rt.session_id
+		WHERE rt.token = $1 AND u.is_active = true
+		FOR UPDATE OF rt
+	`
+
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var (
+		token            RefreshToken
+		userTokenVersion int64
+		sessionRevoked   bool
+	)
+
+	err := tx.QueryRow(ctx, query, hashToken).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.SessionID,
+		&token.TokenVersion,
+		&token.Expiry,
+		&token.RevokedAt,
+		&token.CreatedAt,
+		&userTokenVersion,
+		&sessionRevoked,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, 0, false, ErrNotFound
+		default:
+			return nil, 0, false, err
+		}
+	}
+
+	return &token, userTokenVersion, sessionRevoked, nil
+}
+
+func (s *RefreshTokensStore) revoke(ctx context.Context, tx pgx.Tx, tokenID int64) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.Exec(ctx, query, tokenID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *RefreshTokensStore) revokeFamily(ctx context.Context, tx pgx.Tx, familyID string) error {
+	query := `
+		WITH revoked AS (
+			UPDATE refresh_tokens
+			SET revoked_at = NOW()
+			WHERE family_id = $1 AND revoked_at IS NULL
+			RETURNING session_id
+		)
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE id IN (SELECT session_id FROM revoked) AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.Exec(ctx, query, familyID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *RefreshTokensStore) revokeBySessionID(ctx context.Context, tx pgx.Tx, sessionID int64) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE session_id = $1 AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.Exec(ctx, query, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *RefreshTokensStore) revokeByUserID(ctx context.Context, tx pgx.Tx, userID int64) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.Exec(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func bumpUserTokenVersion(ctx context.Context, tx pgx.Tx, userID int64) error {
+	query := `
+		UPDATE users
+		SET token_version = token_version + 1, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.Exec(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	PermissionAdminAccess        = "admin:access"
+	PermissionGenresWrite        = "genres:write"
+	PermissionNovelsWrite        = "novels:write"
+	PermissionNovelsDelete       = "novels:delete"
+	PermissionNovelsManageAny    = "novels:manage_any"
+	PermissionChaptersPublish    = "chapters:publish"
+	PermissionChaptersReadLocked = "chapters:read_locked"
+	PermissionInvoicesRead       = "invoices:read"
+	PermissionUsersManage        = "users:manage"
+	PermissionRolesManage        = "roles:manage"
+	PermissionAuditRead          = "audit:read"
+	PermissionCoinsManage        = "coins:manage"
+	PermissionPlansManage        = "plans:manage"
+)
+
+const (
+	RoleUser   = "user"
+	RoleAuthor = "author"
+)
+
+type Role struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// HasPermission reports whether the user's role grants every permission.
+func (u *User) HasPermission(permissions ...string) bool {
+	for _, p := range permissions {
+		if !slices.Contains(u.Permissions, p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CanManageNovel reports whether the user may change novel and its chapters:
+// authors only their own, staff with novels:manage_any every novel.
+func (u *User) CanManageNovel(novel *Novel) bool {
+	if u.HasPermission(PermissionNovelsManageAny) {
+		return true
+	}
+
+	return novel.OwnerID != nil && *novel.OwnerID == u.ID
+}
+
+// IsStaff reports whether the user can open the admin dashboard.
+func (u *User) IsStaff() bool {
+	return u.HasPermission(PermissionAdminAccess)
+}
+
+type RolesStore struct {
+	db *pgxpool.Pool
+}
+
+func (s *RolesStore) GetAll(ctx context.Context) ([]*Role, error) {
+	query := `
+		SELECT r.id, r.name, r.description, r.created_at,
+			ARRAY(
+				SELECT p.name
+				FROM role_permissions rp
+				JOIN permissions p ON p.id = rp.permission_id
+				WHERE rp.role_id = r.id
+				ORDER BY p.name
+			)
+		FROM roles r
+		ORDER BY r.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []*Role{}
+	for rows.Next() {
+		var role Role
+		err := rows.Scan(
+			&role.ID,
+			&role.Name,
+			&role.Description,
+			&role.CreatedAt,
+			&role.Permissions,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, &role)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// AssignToUser changes the role of a user. It returns ErrNotFound when either
+// the user or the role does not exist.
+func (s *RolesStore) AssignToUser(ctx context.Context, userID int64, role string) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		if err := s.checkExists(ctx, tx, role); err != nil {
+			return err
+		}
+
+		return audited(ctx, tx, AuditUserRoleAssign, "user", userID, func() error {
+			query := `
+				UPDATE users
+				SET role = $1, updated_at = NOW()
+				WHERE id = $2
+			`
+
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+			defer cancel()
+
+			cmdTag, err := tx.Exec(ctx, query, role, userID)
+			if err != nil {
+				return err
+			}
+
+			if cmdTag.RowsAffected() == 0 {
+				return ErrNotFound
+			}
+
+			return nil
+		})
+	})
+}
+
+func (s *RolesStore) checkExists(ctx context.Context, tx pgx.Tx, role string) error {
+	query := `
+		SELECT id FROM roles WHERE name = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var id int64
+	err := tx.QueryRow(ctx, query, role).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
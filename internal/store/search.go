@@ -0,0 +1,176 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SearchLanguages are the text search configurations a novel can be indexed
+// with. simple does no stemming, which suits names and mixed-language text.
+var SearchLanguages = []string{"english", "indonesian", "simple"}
+
+const DefaultSearchLanguage = "english"
+
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+
+// novelSearch is a search box query as SQL on novels n.
+type novelSearch struct {
+	condition string
+	rank      string
+	headline  string
+}
+
+// newNovelSearch matches every word of text as a prefix in each search
+// language, so a novel is found whatever language it is indexed with. Titles
+// and authors also match by trigram word similarity to tolerate typos.
+// Chapter bodies are only searched when inChapters is set.
+func newNovelSearch(text string, inChapters bool, args []any) (*novelSearch, []any) {
+	args = append(args, text)
+	textArg := fmt.Sprintf("$%d", len(args))
+
+	matches := []string{
+		textArg + " <% n.title",
+		textArg + " <% n.author",
+	}
+	rank := fmt.Sprintf("word_similarity(%s, n.title)", textArg)
+	headline := "''"
+
+	if prefix := prefixQuery(text); prefix != "" {
+		args = append(args, prefix)
+		prefixArg := fmt.Sprintf("$%d", len(args))
+
+		queries := make([]string, len(SearchLanguages))
+		for i, lang := range SearchLanguages {
+			queries[i] = fmt.Sprintf("to_tsquery('%s', %s)", lang, prefixArg)
+		}
+		tsquery := "(" + strings.Join(queries, " || ") + ")"
+
+		matches = append([]string{"n.search_vector @@ " + tsquery}, matches...)
+		rank = fmt.Sprintf("ts_rank(n.search_vector, %s) + %s", tsquery, rank)
+		headline = fmt.Sprintf("ts_headline(n.language::regconfig, n.synopsis, %s, '%s')", tsquery, headlineOptions)
+
+		if inChapters {
+			matches = append(matches, fmt.Sprintf(
+				"EXISTS (SELECT 1 FROM chapters sc WHERE sc.novel_id = n.id AND sc.published_at IS NOT NULL AND sc.content_fts @@ to_tsquery('simple', %s))",
+				prefixArg,
+			))
+		}
+	}
+
+	return &novelSearch{
+		condition: "(" + strings.Join(matches, " OR ") + ")",
+		rank:      "(" + rank + ")::float8",
+		headline:  headline,
+	}, args
+}
+
+// prefixQuery turns text into a to_tsquery expression matching all of its
+// words as prefixes. Anything but letters and digits is dropped so user input
+// can't inject tsquery operators.
+func prefixQuery(text string) string {
+	terms := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for i, term := range terms {
+		terms[i] = term + ":*"
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+// escapeHeadline HTML-escapes a ts_headline snippet but keeps the <mark> tags
+// it added, since the synopsis itself is author input.
+func escapeHeadline(s string) string {
+	return strings.NewReplacer(
+		"&lt;mark&gt;", "<mark>",
+		"&lt;/mark&gt;", "</mark>",
+	).Replace(html.EscapeString(s))
+}
+
+// Suggestion is a lightweight search box completion. NovelID or GenreID is set
+// for novel and genre suggestions.
+type Suggestion struct {
+	Type    string `json:"type"`
+	Text    string `json:"text"`
+	NovelID *int64 `json:"novel_id,omitempty"`
+	GenreID *int32 `json:"genre_id,omitempty"`
+}
+
+type SearchStore struct {
+	db *pgxpool.Pool
+}
+
+// Suggest returns up to limit novel titles, authors and genres each that
+// contain q or are similar to it, prefix matches first. Only public novels
+// are suggested.
+func (s *SearchStore) Suggest(ctx context.Context, q string, limit int) ([]*Suggestion, error) {
+	query := `
+		(
+			SELECT 'novel', n.title, n.id, NULL::int
+			FROM novels n
+			WHERE (n.title ILIKE $2 OR $1 <% n.title) AND ` + novelVisibleExpr + `
+			ORDER BY n.title ILIKE $3 DESC, word_similarity($1, n.title) DESC, n.title
+			LIMIT $4
+		)
+		UNION ALL
+		(
+			SELECT 'author', n.author, NULL::bigint, NULL::int
+			FROM novels n
+			WHERE (n.author ILIKE $2 OR $1 <% n.author) AND ` + novelVisibleExpr + `
+			GROUP BY n.author
+			ORDER BY n.author ILIKE $3 DESC, word_similarity($1, n.author) DESC, n.author
+			LIMIT $4
+		)
+		UNION ALL
+		(
+			SELECT 'genre', name, NULL::bigint, id
+			FROM genres
+			WHERE name ILIKE $2 OR $1 <% name
+			ORDER BY name ILIKE $3 DESC, word_similarity($1, name) DESC, name
+			LIMIT $4
+		)
+	`
+
+	pattern := escapeLike(q)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, query, q, "%"+pattern+"%", pattern+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []*Suggestion{}
+	for rows.Next() {
+		var suggestion Suggestion
+		err := rows.Scan(
+			&suggestion.Type,
+			&suggestion.Text,
+			&suggestion.NovelID,
+			&suggestion.GenreID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Session struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	DeviceName string    `json:"device_name"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Current    bool      `json:"current"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type SessionsStore struct {
+	db            *pgxpool.Pool
+	refreshTokens *RefreshTokensStore
+}
+
+// Create opens a session for a fresh login together with the first refresh
+// token of its family.
+func (s *SessionsStore) Create(ctx context.Context, session *Session, refreshToken *RefreshToken, hashToken string) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		if err := s.create(ctx, tx, session); err != nil {
+			return err
+		}
+
+		refreshToken.SessionID = session.ID
+		if err := s.refreshTokens.create(ctx, tx, refreshToken, hashToken); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *SessionsStore) create(ctx context.Context, tx pgx.Tx, session *Session) error {
+	query := `
+		INSERT INTO sessions (user_id, device_name, ip, user_agent)
+		VALUES ($1, $2, $3, $4) RETURNING id, last_seen_at, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRow(
+		ctx,
+		query,
+		session.UserID,
+		session.DeviceName,
+		session.IP,
+		session.UserAgent,
+	).Scan(&session.ID, &session.LastSeenAt, &session.CreatedAt)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Touch records activity on a live session. It returns ErrNotFound when the
+// session was revoked or belongs to another user.
+func (s *SessionsStore) Touch(ctx context.Context, sessionID, userID int64, ip string) (*Session, error) {
+	query := `
+		UPDATE sessions
+		SET last_seen_at = NOW(), ip = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		RETURNING id, user_id, device_name, ip, user_agent, last_seen_at, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var session Session
+	err := s.db.QueryRow(ctx, query, sessionID, userID, ip).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.DeviceName,
+		&session.IP,
+		&session.UserAgent,
+		&session.LastSeenAt,
+		&session.CreatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &session, nil
+}
+
+func (s *SessionsStore) GetByUserID(ctx context.Context, userID int64) ([]*Session, error) {
+	query := `
+		SELECT id, user_id, device_name, ip, user_agent, last_seen_at, created_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY last_seen_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.DeviceName,
+			&session.IP,
+			&session.UserAgent,
+			&session.LastSeenAt,
+			&session.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Revoke signs a single device out by revoking its session and every refresh
+// token issued to it.
+func (s *SessionsStore) Revoke(ctx context.Context, userID, sessionID int64) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		query := `
+			UPDATE sessions
+			SET revoked_at = NOW()
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		cmdTag, err := tx.Exec(ctx, query, sessionID, userID)
+		if err != nil {
+			return err
+		}
+
+		if cmdTag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+
+		return s.refreshTokens.revokeBySessionID(ctx, tx, sessionID)
+	})
+}
+
+// RevokeAll signs the user out everywhere, including access tokens that were
+// issued before sessions were tracked.
+func (s *SessionsStore) RevokeAll(ctx context.Context, userID int64) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		if err := s.revokeAll(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		if err := s.refreshTokens.revokeByUserID(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		return audited(ctx, tx, AuditUserSessionsRevoke, "user", userID, func() error {
+			return bumpUserTokenVersion(ctx, tx, userID)
+		})
+	})
+}
+
+func (s *SessionsStore) revokeAll(ctx context.Context, tx pgx.Tx, userID int64) error {
+	query := `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.Exec(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
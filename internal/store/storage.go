@@ -20,14 +20,28 @@ type Storage struct {
 		Create(context.Context, pgx.Tx, *User) error
 		CreateAndInvite(context.Context, *User, string, time.Duration) error
 		Activate(context.Context, string) error
+		Reinvite(context.Context, string, string, time.Duration) (*User, error)
+		DeleteExpiredInvitations(context.Context) (int64, error)
+		DeleteUnactivated(context.Context, time.Duration) (int64, error)
 		GetByEmail(context.Context, string) (*User, error)
 		GetByID(context.Context, int64) (*User, error)
 		Delete(context.Context, int64) error
+		SoftDelete(context.Context, int64) (time.Time, error)
+		Restore(context.Context, int64) error
+		PurgeDeleted(context.Context, time.Duration) (int64, error)
 		Update(context.Context, *User) error
+		UpdatePassword(context.Context, *User) error
+		CreateEmailChangeReq(context.Context, string, int64, string, time.Duration) error
+		DeleteEmailChangeReq(context.Context, int64) error
+		ConfirmEmailChange(context.Context, string) error
 		CreateForgotPassReq(context.Context, string, int64, time.Duration) error
 		DeleteForgotPassReq(context.Context, string) error
+		GetByResetToken(context.Context, string) (*User, error)
 		ResetPassword(context.Context, string, string) error
-		Webhook(context.Context, *User, *Invoice) error
+		RecordFailedLogin(context.Context, *User, int, time.Duration) error
+		ResetFailedLogins(context.Context, int64) error
+		Unlock(context.Context, int64) error
+		Webhook(context.Context, *WebhookEvent, *Invoice) (int64, error)
 		PurchaseChapter(context.Context, int64, int64, *UserUnlock) error
 	}
 
@@ -35,9 +49,11 @@ type Storage struct {
 		Create(context.Context, pgx.Tx, *Novel) error
 		CreateNovelAndInsertGenres(context.Context, *Novel, []int32) error
 		GetByID(context.Context, int64, int64) (*Novel, error)
-		GetAllNovel(context.Context, string, string) ([]*Novel, error)
-		GetNovelsFromGenreID(context.Context, int32) ([]*Novel, error)
+		List(context.Context, NovelFilter) (*Page[*Novel], error)
+		GetByOwnerID(context.Context, int64) ([]*Novel, error)
 		Update(context.Context, *Novel) error
+		SetStatus(context.Context, *Novel, string, *time.Time) error
+		PublishScheduled(context.Context) (int64, error)
 		UpdateNovelGenres(context.Context, int64, []int32) error
 		Delete(context.Context, int64) error
 	}
@@ -53,14 +69,16 @@ type Storage struct {
 
 	Chapters interface {
 		Create(context.Context, *Chapter) error
-		GetBySlug(context.Context, string) (*Chapter, error)
-		GetChaptersFromNovelID(context.Context, int64, int64) ([]*Chapter, error)
+		GetBySlug(context.Context, string, bool) (*Chapter, error)
+		GetChaptersFromNovelID(context.Context, int64, int64, bool) ([]*Chapter, error)
 		Update(context.Context, *Chapter) error
 		Delete(context.Context, string) error
+		ReleaseDue(context.Context) ([]*Chapter, error)
 	}
 
 	Histories interface {
 		Create(context.Context, *History) error
+		GetByUserID(context.Context, int64) ([]*History, error)
 	}
 
 	Invoices interface {
@@ -72,29 +90,117 @@ type Storage struct {
 
 	UserUnlocks interface {
 		CheckUser(context.Context, int64, string) error
+		GetByUserID(context.Context, int64) ([]*UserUnlock, error)
 	}
 
 	Bookmarks interface {
 		Create(context.Context, *Bookmark) error
 		GetByUserID(context.Context, int64) ([]*Bookmark, error)
+		List(context.Context, int64, NovelFilter) (*Page[*Bookmark], error)
 		Delete(context.Context, int64) error
 		GetByID(context.Context, int64) (*Bookmark, error)
+		GetSubscribers(context.Context, int64) ([]*User, error)
+	}
+
+	RefreshTokens interface {
+		GetByToken(context.Context, string) (*RefreshToken, error)
+		Rotate(context.Context, string, string, time.Duration) (*RefreshToken, error)
+		RevokeFamily(context.Context, int64, string, bool) error
+	}
+
+	TwoFactor interface {
+		SetSecret(context.Context, int64, string) error
+		Enable(context.Context, int64, int64, []string) error
+		Disable(context.Context, int64) error
+		UseStep(context.Context, int64, int64) error
+		UseRecoveryCode(context.Context, int64, string) error
+		CreateChallenge(context.Context, string, *TwoFactorChallenge, time.Duration) error
+		GetChallenge(context.Context, string) (*TwoFactorChallenge, error)
+		DeleteChallenge(context.Context, string) error
+	}
+
+	Sessions interface {
+		Create(context.Context, *Session, *RefreshToken, string) error
+		Touch(context.Context, int64, int64, string) (*Session, error)
+		GetByUserID(context.Context, int64) ([]*Session, error)
+		Revoke(context.Context, int64, int64) error
+		RevokeAll(context.Context, int64) error
+	}
+
+	Roles interface {
+		GetAll(context.Context) ([]*Role, error)
+		AssignToUser(context.Context, int64, string) error
+	}
+
+	Audit interface {
+		GetAll(context.Context, AuditFilter) ([]*AuditEvent, error)
+	}
+
+	Search interface {
+		Suggest(context.Context, string, int) ([]*Suggestion, error)
+	}
+
+	WebhookEvents interface {
+		Record(context.Context, *WebhookEvent) error
+		Fail(context.Context, int64, error) error
+	}
+
+	Coins interface {
+		Grant(context.Context, *CoinTransaction) error
+		History(context.Context, int64, string, int, bool) (*Page[*CoinTransaction], error)
+		GetByUserID(context.Context, int64) ([]*CoinTransaction, error)
+		Reconcile(context.Context) ([]*CoinMismatch, error)
+	}
+
+	CoinPlans interface {
+		Create(context.Context, *CoinPlan) error
+		GetAll(context.Context) ([]*CoinPlan, error)
+		GetActive(context.Context) ([]*CoinPlan, error)
+		GetByID(context.Context, int64) (*CoinPlan, error)
+		GetActiveByCode(context.Context, string) (*CoinPlan, error)
+		Update(context.Context, *CoinPlan) error
+		Delete(context.Context, int64) error
+	}
+
+	Identities interface {
+		CreateState(context.Context, string, *OIDCState, time.Duration) error
+		ConsumeState(context.Context, string) (*OIDCState, error)
+		GetByProviderSubject(context.Context, string, string) (*Identity, error)
+		GetByUserID(context.Context, int64) ([]*Identity, error)
+		LinkByEmail(context.Context, *Identity) error
+		CreateWithUser(context.Context, *User, *Identity, string, time.Duration) error
+		Delete(context.Context, int64, string) error
 	}
 }
 
 func NewStorage(db *pgxpool.Pool) Storage {
 	invStore := &InvoicesStore{db}
 	unStore := &UserUnlockStore{db}
+	rtStore := &RefreshTokensStore{db}
+	coinsStore := &CoinsStore{db}
+	whStore := &WebhookEventsStore{db}
+	sessionsStore := &SessionsStore{db, rtStore}
+	usersStore := &UsersStore{db, invStore, unStore, coinsStore, whStore, sessionsStore}
 
 	return Storage{
-		Users:       &UsersStore{db, invStore, unStore},
-		Novels:      &NovelsStore{db},
-		Genres:      &GenresStore{db},
-		Chapters:    &ChaptersStore{db},
-		Histories:   &HistoriesStore{db},
-		Invoices:    invStore,
-		UserUnlocks: unStore,
-		Bookmarks:   &BookmarkStore{db},
+		Users:         usersStore,
+		Novels:        &NovelsStore{db},
+		Genres:        &GenresStore{db},
+		Chapters:      &ChaptersStore{db},
+		Histories:     &HistoriesStore{db},
+		Invoices:      invStore,
+		UserUnlocks:   unStore,
+		Bookmarks:     &BookmarkStore{db},
+		RefreshTokens: rtStore,
+		Sessions:      sessionsStore,
+		TwoFactor:     &TwoFactorStore{db},
+		Identities:    &IdentitiesStore{db, usersStore},
+		Roles:         &RolesStore{db},
+		Audit:         &AuditStore{db},
+		Search:        &SearchStore{db},
+		Coins:         coinsStore,
+		WebhookEvents: whStore,
+		CoinPlans:     &CoinPlansStore{db},
 	}
 }
 
@@ -0,0 +1,255 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrTOTPCodeReused = errors.New("this code has already been used")
+
+type TwoFactorChallenge struct {
+	UserID int64
+	Device string
+}
+
+type TwoFactorStore struct {
+	db *pgxpool.Pool
+}
+
+// SetSecret stores a pending secret. It only takes effect once Enable is
+// called after the user proves they scanned it.
+func (s *TwoFactorStore) SetSecret(ctx context.Context, userID int64, secret string) error {
+	query := `
+		UPDATE users
+		SET totp_secret = $1, totp_enabled = false, totp_last_step = 0
+		WHERE id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	cmdTag, err := s.db.Exec(ctx, query, secret, userID)
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Enable turns on two-factor authentication and replaces any previous
+// recovery codes with the given hashes.
+func (s *TwoFactorStore) Enable(ctx context.Context, userID int64, step int64, recoveryCodes []string) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		err := audited(ctx, tx, AuditTwoFactorEnable, "user", userID, func() error {
+			query := `
+				UPDATE users
+				SET totp_enabled = true, totp_last_step = $1
+				WHERE id = $2 AND totp_secret IS NOT NULL
+			`
+
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+			defer cancel()
+
+			cmdTag, err := tx.Exec(ctx, query, step, userID)
+			if err != nil {
+				return err
+			}
+
+			if cmdTag.RowsAffected() == 0 {
+				return ErrNotFound
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := s.deleteRecoveryCodes(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		return s.insertRecoveryCodes(ctx, tx, userID, recoveryCodes)
+	})
+}
+
+func (s *TwoFactorStore) Disable(ctx context.Context, userID int64) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		err := audited(ctx, tx, AuditTwoFactorDisable, "user", userID, func() error {
+			query := `
+				UPDATE users
+				SET totp_secret = NULL, totp_enabled = false, totp_last_step = 0
+				WHERE id = $1
+			`
+
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+			defer cancel()
+
+			_, err := tx.Exec(ctx, query, userID)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.deleteRecoveryCodes(ctx, tx, userID)
+	})
+}
+
+// UseStep consumes a TOTP time step so the same code cannot be replayed.
+func (s *TwoFactorStore) UseStep(ctx context.Context, userID int64, step int64) error {
+	query := `
+		UPDATE users
+		SET totp_last_step = $1
+		WHERE id = $2 AND totp_last_step < $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	cmdTag, err := s.db.Exec(ctx, query, step, userID)
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return ErrTOTPCodeReused
+	}
+
+	return nil
+}
+
+func (s *TwoFactorStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	query := `
+		UPDATE user_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code = $2 AND used_at IS NULL
+	`
+
+	hash := sha256.Sum256([]byte(code))
+	hashCode := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	cmdTag, err := s.db.Exec(ctx, query, userID, hashCode)
+	if err != nil {
+		return err
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *TwoFactorStore) CreateChallenge(ctx context.Context, token string, challenge *TwoFactorChallenge, exp time.Duration) error {
+	query := `
+		INSERT INTO two_factor_challenges (token, user_id, device, expiry)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.Exec(ctx, query, token, challenge.UserID, challenge.Device, time.Now().Add(exp))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *TwoFactorStore) GetChallenge(ctx context.Context, token string) (*TwoFactorChallenge, error) {
+	query := `
+		SELECT user_id, device
+		FROM two_factor_challenges
+		WHERE token = $1 AND expiry > $2
+	`
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var challenge TwoFactorChallenge
+	err := s.db.QueryRow(ctx, query, hashToken, time.Now()).Scan(
+		&challenge.UserID,
+		&challenge.Device,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &challenge, nil
+}
+
+func (s *TwoFactorStore) DeleteChallenge(ctx context.Context, token string) error {
+	query := `
+		DELETE FROM two_factor_challenges WHERE token = $1
+	`
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.Exec(ctx, query, hashToken)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *TwoFactorStore) insertRecoveryCodes(ctx context.Context, tx pgx.Tx, userID int64, codes []string) error {
+	query := `
+		INSERT INTO user_recovery_codes (user_id, code)
+		SELECT $1, UNNEST($2::text[])
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.Exec(ctx, query, userID, codes)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *TwoFactorStore) deleteRecoveryCodes(ctx context.Context, tx pgx.Tx, userID int64) error {
+	query := `
+		DELETE FROM user_recovery_codes WHERE user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.Exec(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -79,3 +79,45 @@ func (un *UserUnlockStore) unlockChapter(ctx context.Context, tx pgx.Tx, userUnl
 
 	return nil
 }
+
+func (un *UserUnlockStore) GetByUserID(ctx context.Context, userID int64) ([]*UserUnlock, error) {
+	query := `
+		SELECT id, user_id, chapter_slug, created_at
+		FROM user_unlocks
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := un.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	userUnlocks := []*UserUnlock{}
+	for rows.Next() {
+		var userUnlock UserUnlock
+		err := rows.Scan(
+			&userUnlock.ID,
+			&userUnlock.UserID,
+			&userUnlock.ChapterSlug,
+			&userUnlock.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		userUnlocks = append(userUnlocks, &userUnlock)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return userUnlocks, nil
+}
@@ -9,7 +9,6 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -18,17 +17,23 @@ var (
 )
 
 type User struct {
-	ID           int64     `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	Password     password  `json:"-"`
-	IsActive     bool      `json:"is_active"`
-	Role         string    `json:"-"`
-	TokenVersion int64     `json:"token_version"`
-	Coin         int64     `json:"coin"`
-	ImageURL     string    `json:"image_url"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                  int64      `json:"id"`
+	Username            string     `json:"username"`
+	Email               string     `json:"email"`
+	Password            password   `json:"-"`
+	IsActive            bool       `json:"is_active"`
+	Role                string     `json:"-"`
+	Permissions         []string   `json:"-"`
+	TokenVersion        int64      `json:"token_version"`
+	Coin                int64      `json:"coin"`
+	ImageURL            string     `json:"image_url"`
+	FailedLoginAttempts int        `json:"-"`
+	LockedUntil         *time.Time `json:"locked_until,omitempty"`
+	TOTPSecret          string     `json:"-"`
+	TOTPEnabled         bool       `json:"two_factor_enabled"`
+	DeletedAt           *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
 }
 
 type password struct {
@@ -37,7 +42,7 @@ type password struct {
 }
 
 func (p *password) Set(text string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(text), bcrypt.DefaultCost)
+	hash, err := DefaultPasswordHasher.Hash(text)
 	if err != nil {
 		return err
 	}
@@ -49,14 +54,23 @@ func (p *password) Set(text string) error {
 }
 
 func (p *password) Verify(pass string) bool {
-	err := bcrypt.CompareHashAndPassword(p.hash, []byte(pass))
-	return err == nil
+	ok, err := verifyPasswordHash(p.hash, pass)
+	return err == nil && ok
+}
+
+// NeedsRehash reports whether the stored hash uses another algorithm or
+// older parameters than DefaultPasswordHasher.
+func (p *password) NeedsRehash() bool {
+	return !DefaultPasswordHasher.Current(p.hash)
 }
 
 type UsersStore struct {
-	db          *pgxpool.Pool
-	invoices    *InvoicesStore
-	userUnlocks *UserUnlockStore
+	db            *pgxpool.Pool
+	invoices      *InvoicesStore
+	userUnlocks   *UserUnlockStore
+	coins         *CoinsStore
+	webhookEvents *WebhookEventsStore
+	sessions      *SessionsStore
 }
 
 func (s *UsersStore) Create(ctx context.Context, tx pgx.Tx, user *User) error {
@@ -92,7 +106,8 @@ func (s *UsersStore) Create(ctx context.Context, tx pgx.Tx, user *User) error {
 
 func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT id, username, email, password, is_active, token_version, created_at, updated_at
+		SELECT id, username, email, password, is_active, role, token_version, failed_login_attempts, locked_until,
+			COALESCE(totp_secret, ''), totp_enabled, deleted_at, created_at, updated_at
 		FROM users
 		WHERE email = $1 AND is_active = true
 	`
@@ -111,7 +126,13 @@ func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error
 		&user.Email,
 		&user.Password.hash,
 		&user.IsActive,
+		&user.Role,
 		&user.TokenVersion,
+		&user.FailedLoginAttempts,
+		&user.LockedUntil,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.DeletedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -130,7 +151,15 @@ func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error
 
 func (s *UsersStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 	query := `
-		SELECT id, username, email, password, is_active, role, token_version, coin, image_url, created_at, updated_at
+		SELECT id, username, email, password, is_active, role, token_version, coin, image_url,
+			failed_login_attempts, locked_until, COALESCE(totp_secret, ''), totp_enabled, deleted_at, created_at, updated_at,
+			ARRAY(
+				SELECT p.name
+				FROM roles r
+				JOIN role_permissions rp ON rp.role_id = r.id
+				JOIN permissions p ON p.id = rp.permission_id
+				WHERE r.name = users.role
+			)
 		FROM users
 		WHERE id = $1 AND is_active = true
 	`
@@ -153,8 +182,14 @@ func (s *UsersStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 		&user.TokenVersion,
 		&user.Coin,
 		&user.ImageURL,
+		&user.FailedLoginAttempts,
+		&user.LockedUntil,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.DeletedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.Permissions,
 	)
 
 	if err != nil {
@@ -170,6 +205,22 @@ func (s *UsersStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 }
 
 func (s *UsersStore) Update(ctx context.Context, user *User) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		return auditedAs(ctx, tx, userUpdateAction, "user", user.ID, func() error {
+			return s.update(ctx, tx, user)
+		})
+	})
+}
+
+func userUpdateAction(changed map[string]any) string {
+	if _, ok := changed["password"]; ok {
+		return AuditUserPasswordChange
+	}
+
+	return AuditUserUpdate
+}
+
+func (s *UsersStore) update(ctx context.Context, tx pgx.Tx, user *User) error {
 	query := `
 		update users
 		SET username = $1, email = $2, token_version = $3, password = $4, image_url = $5, updated_at = $6
@@ -180,7 +231,7 @@ func (s *UsersStore) Update(ctx context.Context, user *User) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRow(
+	err := tx.QueryRow(
 		ctx,
 		query,
 		user.Username,
@@ -204,6 +255,24 @@ func (s *UsersStore) Update(ctx context.Context, user *User) error {
 	return nil
 }
 
+// UpdatePassword stores a new hash of the same password. Unlike a password
+// change it keeps existing sessions.
+func (s *UsersStore) UpdatePassword(ctx context.Context, user *User) error {
+	query := `
+		UPDATE users SET password = $1 WHERE id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.Exec(ctx, query, user.Password.hash, user.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (s *UsersStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
 	return withTx(s.db, ctx, func(tx pgx.Tx) error {
 		if err := s.Create(ctx, tx, user); err != nil {
@@ -237,11 +306,11 @@ func (s *UsersStore) createUserInvitation(ctx context.Context, tx pgx.Tx, token
 
 func (s *UsersStore) Delete(ctx context.Context, userID int64) error {
 	return withTx(s.db, ctx, func(tx pgx.Tx) error {
-		if err := s.delete(ctx, tx, userID); err != nil {
+		if err := s.deleteUserInvitations(ctx, tx, userID); err != nil {
 			return err
 		}
 
-		if err := s.deleteUserInvitations(ctx, tx, userID); err != nil {
+		if err := s.delete(ctx, tx, userID); err != nil {
 			return err
 		}
 
@@ -265,6 +334,85 @@ func (s *UsersStore) delete(ctx context.Context, tx pgx.Tx, userID int64) error
 	return nil
 }
 
+// SoftDelete schedules the account for deletion. It stays restorable until
+// PurgeDeleted removes it.
+func (s *UsersStore) SoftDelete(ctx context.Context, userID int64) (time.Time, error) {
+	var deletedAt time.Time
+	err := withTx(s.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditUserDelete, "user", userID, func() error {
+			query := `
+				UPDATE users
+				SET deleted_at = COALESCE(deleted_at, NOW())
+				WHERE id = $1
+				RETURNING deleted_at
+			`
+
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+			defer cancel()
+
+			err := tx.QueryRow(ctx, query, userID).Scan(&deletedAt)
+			if err != nil {
+				switch {
+				case errors.Is(err, pgx.ErrNoRows):
+					return ErrNotFound
+				default:
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+
+	return deletedAt, err
+}
+
+func (s *UsersStore) Restore(ctx context.Context, userID int64) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditUserRestore, "user", userID, func() error {
+			query := `
+				UPDATE users SET deleted_at = NULL WHERE id = $1
+			`
+
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+			defer cancel()
+
+			_, err := tx.Exec(ctx, query, userID)
+			return err
+		})
+	})
+}
+
+// PurgeDeleted removes accounts soft deleted more than gracePeriod ago. Their
+// invoices are kept for accounting but no longer point at anyone.
+func (s *UsersStore) PurgeDeleted(ctx context.Context, gracePeriod time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-gracePeriod)
+
+	var purged int64
+	err := withTx(s.db, ctx, func(tx pgx.Tx) error {
+		if err := s.invoices.anonymizeDeleted(ctx, tx, cutoff); err != nil {
+			return err
+		}
+
+		query := `
+			DELETE FROM users WHERE deleted_at < $1
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		cmdTag, err := tx.Exec(ctx, query, cutoff)
+		if err != nil {
+			return err
+		}
+
+		purged = cmdTag.RowsAffected()
+		return nil
+	})
+
+	return purged, err
+}
+
 func (s *UsersStore) Activate(ctx context.Context, token string) error {
 	return withTx(s.db, ctx, func(tx pgx.Tx) error {
 		user, err := s.getUserFromInvitation(ctx, tx, token)
@@ -323,7 +471,7 @@ func (s *UsersStore) getUserFromInvitation(ctx context.Context, tx pgx.Tx, token
 func (s *UsersStore) changeIsActive(ctx context.Context, tx pgx.Tx, user *User) error {
 	query := `
 		UPDATE users 
-		SET is_active = $1
+		SET is_active = $1, activated_at = COALESCE(activated_at, NOW())
 		WHERE id = $2
 	`
 
@@ -354,6 +502,223 @@ func (s *UsersStore) deleteUserInvitations(ctx context.Context, tx pgx.Tx, userI
 	return nil
 }
 
+// Reinvite replaces the pending invitation of a user that has not activated
+// their account yet. It returns ErrNotFound for unknown or active accounts.
+func (s *UsersStore) Reinvite(ctx context.Context, email string, token string, invitationExp time.Duration) (*User, error) {
+	var user *User
+
+	err := withTx(s.db, ctx, func(tx pgx.Tx) error {
+		var err error
+		user, err = s.getPendingByEmail(ctx, tx, email)
+		if err != nil {
+			return err
+		}
+
+		if err := s.deleteUserInvitations(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		return s.createUserInvitation(ctx, tx, token, user.ID, invitationExp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *UsersStore) getPendingByEmail(ctx context.Context, tx pgx.Tx, email string) (*User, error) {
+	query := `
+		SELECT id, username, email, created_at
+		FROM users
+		WHERE email = $1 AND is_active = false AND activated_at IS NULL
+		FOR UPDATE
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := tx.QueryRow(ctx, query, email).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// DeleteExpiredInvitations removes invitation tokens that can no longer be
+// used.
+func (s *UsersStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM user_invitations WHERE expiry <= $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	cmdTag, err := s.db.Exec(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return cmdTag.RowsAffected(), nil
+}
+
+// DeleteUnactivated removes accounts that were never activated within
+// gracePeriod of signing up and have no invitation left to do so.
+func (s *UsersStore) DeleteUnactivated(ctx context.Context, gracePeriod time.Duration) (int64, error) {
+	query := `
+		DELETE FROM users u
+		WHERE u.is_active = false
+			AND u.activated_at IS NULL
+			AND u.created_at < $1
+			AND NOT EXISTS (
+				SELECT 1 FROM user_invitations ui
+				WHERE ui.user_id = u.id AND ui.expiry > $2
+			)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	now := time.Now()
+	cmdTag, err := s.db.Exec(ctx, query, now.Add(-gracePeriod), now)
+	if err != nil {
+		return 0, err
+	}
+
+	return cmdTag.RowsAffected(), nil
+}
+
+// CreateEmailChangeReq stores a pending email change, replacing any earlier
+// request of the same user.
+func (s *UsersStore) CreateEmailChangeReq(ctx context.Context, token string, userID int64, newEmail string, expiry time.Duration) error {
+	query := `
+		INSERT INTO email_change_requests (token, user_id, new_email, expiry)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET token = EXCLUDED.token, new_email = EXCLUDED.new_email, expiry = EXCLUDED.expiry
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.Exec(ctx, query, token, userID, newEmail, time.Now().Add(expiry))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UsersStore) DeleteEmailChangeReq(ctx context.Context, userID int64) error {
+	query := `
+		DELETE FROM email_change_requests WHERE user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.Exec(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange swaps in the requested address and bumps the token
+// version so every existing token is signed out.
+func (s *UsersStore) ConfirmEmailChange(ctx context.Context, token string) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		userID, newEmail, err := s.getEmailChangeReq(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		err = audited(ctx, tx, AuditUserEmailChange, "user", userID, func() error {
+			return s.changeEmail(ctx, tx, userID, newEmail)
+		})
+		if err != nil {
+			return err
+		}
+
+		query := `
+			DELETE FROM email_change_requests WHERE user_id = $1
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err = tx.Exec(ctx, query, userID)
+		return err
+	})
+}
+
+func (s *UsersStore) getEmailChangeReq(ctx context.Context, tx pgx.Tx, token string) (int64, string, error) {
+	query := `
+		SELECT user_id, new_email
+		FROM email_change_requests
+		WHERE token = $1 AND expiry > $2
+		FOR UPDATE
+	`
+
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var userID int64
+	var newEmail string
+	err := tx.QueryRow(ctx, query, hashToken, time.Now()).Scan(&userID, &newEmail)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return 0, "", ErrNotFound
+		default:
+			return 0, "", err
+		}
+	}
+
+	return userID, newEmail, nil
+}
+
+func (s *UsersStore) changeEmail(ctx context.Context, tx pgx.Tx, userID int64, email string) error {
+	query := `
+		UPDATE users
+		SET email = $1, token_version = token_version + 1, updated_at = NOW()
+		WHERE id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.Exec(ctx, query, email, userID)
+	if err != nil {
+		switch {
+		case err.Error() == `ERROR: duplicate key value violates unique constraint "users_email_key" (SQLSTATE 23505)`:
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *UsersStore) CreateForgotPassReq(ctx context.Context, token string, userID int64, expiry time.Duration) error {
 	query := `
 		INSERT INTO forgot_pass_requests (token, user_id, expiry)
@@ -401,10 +766,19 @@ func (s *UsersStore) ResetPassword(ctx context.Context, token string, password s
 			return err
 		}
 
-		user.TokenVersion++
-		user.UpdatedAt = time.Now()
+		err = audited(ctx, tx, AuditUserPasswordReset, "user", user.ID, func() error {
+			return s.resetPassword(ctx, tx, user)
+		})
+		if err != nil {
+			return err
+		}
+
+		// whoever knew the old password is signed out everywhere
+		if err = s.sessions.revokeAll(ctx, tx, user.ID); err != nil {
+			return err
+		}
 
-		if err = s.Update(ctx, user); err != nil {
+		if err = s.sessions.refreshTokens.revokeByUserID(ctx, tx, user.ID); err != nil {
 			return err
 		}
 
@@ -416,6 +790,50 @@ func (s *UsersStore) ResetPassword(ctx context.Context, token string, password s
 	})
 }
 
+// resetPassword stores user's new password hash and bumps the token version,
+// invalidating the access tokens issued before. Only the password is written,
+// as user holds no more than getForgotPassReq loads.
+func (s *UsersStore) resetPassword(ctx context.Context, tx pgx.Tx, user *User) error {
+	query := `
+		UPDATE users
+		SET password = $1, token_version = token_version + 1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING token_version, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRow(ctx, query, user.Password.hash, user.ID).Scan(&user.TokenVersion, &user.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetByResetToken returns the user a live password reset token belongs to, or
+// ErrNotFound when the token is unknown or expired.
+func (s *UsersStore) GetByResetToken(ctx context.Context, token string) (*User, error) {
+	var user *User
+
+	err := withTx(s.db, ctx, func(tx pgx.Tx) error {
+		var err error
+		user, err = s.getForgotPassReq(ctx, tx, token)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
 func (s *UsersStore) getForgotPassReq(ctx context.Context, tx pgx.Tx, token string) (*User, error) {
 	query := `
 		SELECT u.id, u.username, u.email
@@ -449,80 +867,175 @@ func (s *UsersStore) getForgotPassReq(ctx context.Context, tx pgx.Tx, token stri
 	return user, nil
 }
 
-func (s *UsersStore) Webhook(ctx context.Context, user *User, invoice *Invoice) error {
-	return withTx(s.db, ctx, func(tx pgx.Tx) error {
-		if err := s.invoices.update(ctx, tx, invoice); err != nil {
+// RecordFailedLogin counts a failed password attempt. Once maxAttempts is
+// reached the account is locked for lockDuration, doubling with every further
+// failure.
+func (s *UsersStore) RecordFailedLogin(ctx context.Context, user *User, maxAttempts int, lockDuration time.Duration) error {
+	query := `
+		UPDATE users
+		SET failed_login_attempts = failed_login_attempts + 1,
+			locked_until = CASE
+				WHEN failed_login_attempts + 1 >= $2
+				THEN NOW() + make_interval(secs => $3 * power(2, LEAST(failed_login_attempts + 1 - $2, 10)))
+				ELSE locked_until
+			END
+		WHERE id = $1
+		RETURNING failed_login_attempts, locked_until
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRow(
+		ctx,
+		query,
+		user.ID,
+		maxAttempts,
+		lockDuration.Seconds(),
+	).Scan(&user.FailedLoginAttempts, &user.LockedUntil)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrNotFound
+		default:
 			return err
 		}
+	}
 
-		if invoice.Status == "PAID" {
-			if err := s.addCoin(ctx, tx, user); err != nil {
+	return nil
+}
+
+// Unlock is ResetFailedLogins done by staff, so it is audited.
+func (s *UsersStore) Unlock(ctx context.Context, userID int64) error {
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		return audited(ctx, tx, AuditUserUnlock, "user", userID, func() error {
+			query := `
+				UPDATE users
+				SET failed_login_attempts = 0, locked_until = NULL
+				WHERE id = $1
+			`
+
+			ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+			defer cancel()
+
+			cmdTag, err := tx.Exec(ctx, query, userID)
+			if err != nil {
 				return err
 			}
-		}
 
-		return nil
+			if cmdTag.RowsAffected() == 0 {
+				return ErrNotFound
+			}
+
+			return nil
+		})
 	})
 }
 
-func (s *UsersStore) addCoin(ctx context.Context, tx pgx.Tx, user *User) error {
+func (s *UsersStore) ResetFailedLogins(ctx context.Context, userID int64) error {
 	query := `
-		update users
-		SET coin = coin + $1
-		WHERE id = $2
+		UPDATE users
+		SET failed_login_attempts = 0, locked_until = NULL
+		WHERE id = $1
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := tx.Exec(
-		ctx,
-		query,
-		user.Coin,
-		user.ID,
-	)
-
+	cmdTag, err := s.db.Exec(ctx, query, userID)
 	if err != nil {
 		return err
 	}
 
+	if cmdTag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
 	return nil
 }
 
-func (s *UsersStore) PurchaseChapter(ctx context.Context, userID int64, amount int64, userUnlock *UserUnlock) error {
-	return withTx(s.db, ctx, func(tx pgx.Tx) error {
-		if err := s.deductCoin(ctx, tx, userID, amount); err != nil {
+// Webhook applies the invoice status carried by event and returns the coins
+// credited, which are only added the first time the invoice is paid: the
+// coins of its plan as a top-up and its bonus coins as a bonus. A paid
+// invoice never goes back to an unpaid status. The event is marked processed
+// in the same transaction, so a delivery that fails can be retried.
+func (s *UsersStore) Webhook(ctx context.Context, event *WebhookEvent, invoice *Invoice) (int64, error) {
+	var credited int64
+
+	err := withTx(s.db, ctx, func(tx pgx.Tx) error {
+		current, err := s.invoices.lockStatus(ctx, tx, invoice.InvoiceID)
+		if err != nil {
 			return err
 		}
 
-		if err := s.userUnlocks.unlockChapter(ctx, tx, userUnlock); err != nil {
+		switch {
+		case IsInvoicePaid(current) && !IsInvoicePaid(invoice.Status):
+			invoice.Status = current
+		case !IsInvoicePaid(current) && IsInvoicePaid(invoice.Status) && invoice.UserID != 0:
+			topUp := &CoinTransaction{
+				UserID:    invoice.UserID,
+				Kind:      CoinTopUp,
+				Amount:    invoice.Coins,
+				Reference: invoice.InvoiceID,
+			}
+
+			if err := s.coins.record(ctx, tx, topUp); err != nil {
+				return err
+			}
+
+			bonus := &CoinTransaction{
+				UserID:    invoice.UserID,
+				Kind:      CoinBonus,
+				Amount:    invoice.BonusCoins,
+				Reference: invoice.InvoiceID,
+			}
+
+			if err := s.coins.record(ctx, tx, bonus); err != nil {
+				return err
+			}
+			credited = invoice.Coins + invoice.BonusCoins
+		}
+
+		if err := s.invoices.update(ctx, tx, invoice); err != nil {
 			return err
 		}
 
-		return nil
+		return s.webhookEvents.markProcessed(ctx, tx, event)
 	})
+	if err != nil {
+		return 0, err
+	}
+
+	return credited, nil
 }
 
-func (s *UsersStore) deductCoin(ctx context.Context, tx pgx.Tx, userID, amount int64) error {
-	query := `
-		update users
-		SET coin = coin - $1
-		WHERE id = $2
-	`
+// PurchaseChapter debits amount from the user and unlocks the chapter, or
+// does neither: ErrInsufficientCoin when the balance doesn't cover it and
+// ErrAlreadyUnlocked when another purchase of the chapter got there first.
+// A negative amount would credit the buyer and is refused with
+// ErrInvalidPrice.
+func (s *UsersStore) PurchaseChapter(ctx context.Context, userID int64, amount int64, userUnlock *UserUnlock) error {
+	if amount < 0 {
+		return ErrInvalidPrice
+	}
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+	return withTx(s.db, ctx, func(tx pgx.Tx) error {
+		purchase := &CoinTransaction{
+			UserID:    userID,
+			Kind:      CoinChapterPurchase,
+			Amount:    -amount,
+			Reference: userUnlock.ChapterSlug,
+		}
 
-	_, err := tx.Exec(
-		ctx,
-		query,
-		amount,
-		userID,
-	)
+		if err := s.coins.record(ctx, tx, purchase); err != nil {
+			return err
+		}
 
-	if err != nil {
-		return err
-	}
+		if err := s.userUnlocks.unlockChapter(ctx, tx, userUnlock); err != nil {
+			return err
+		}
 
-	return nil
+		return nil
+	})
 }
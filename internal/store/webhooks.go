@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrDuplicateWebhook = errors.New("webhook event already processed")
+
+// WebhookEvent is one delivery of a payment provider callback. Deliveries
+// are deduplicated on the provider's EventID.
+type WebhookEvent struct {
+	ID          int64           `json:"id"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	InvoiceID   string          `json:"invoice_id"`
+	Status      string          `json:"status"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	Error       string          `json:"error"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+type WebhookEventsStore struct {
+	db *pgxpool.Pool
+}
+
+// Record stores a delivery of event, or counts another attempt at one already
+// received. It returns ErrDuplicateWebhook if the event was processed before,
+// so a redelivery after a failure is processed again.
+func (s *WebhookEventsStore) Record(ctx context.Context, event *WebhookEvent) error {
+	query := `
+		INSERT INTO webhook_events (provider, event_id, invoice_id, status, payload)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (provider, event_id) DO UPDATE
+		SET attempts = webhook_events.attempts + 1
+		RETURNING id, attempts, received_at, processed_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRow(
+		ctx,
+		query,
+		event.Provider,
+		event.EventID,
+		event.InvoiceID,
+		event.Status,
+		event.Payload,
+	).Scan(&event.ID, &event.Attempts, &event.ReceivedAt, &event.ProcessedAt)
+	if err != nil {
+		return err
+	}
+
+	if event.ProcessedAt != nil {
+		return ErrDuplicateWebhook
+	}
+
+	return nil
+}
+
+// Fail keeps why processing the event failed, for support to look into.
+func (s *WebhookEventsStore) Fail(ctx context.Context, eventID int64, reason error) error {
+	query := `UPDATE webhook_events SET error = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.Exec(ctx, query, reason.Error(), eventID)
+	return err
+}
+
+func (s *WebhookEventsStore) markProcessed(ctx context.Context, tx pgx.Tx, event *WebhookEvent) error {
+	query := `
+		UPDATE webhook_events
+		SET processed_at = NOW(), error = ''
+		WHERE id = $1
+		RETURNING processed_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return tx.QueryRow(ctx, query, event.ID).Scan(&event.ProcessedAt)
+}
@@ -18,6 +18,7 @@ const Sidebar = () => {
     const handleLogout = () => {
         dispatch(logoutAction());
         localStorage.removeItem("userInfo");
+        localStorage.removeItem("refreshToken");
         navigate("/");
     };
 
@@ -7,7 +7,7 @@ import { listNovelsAPI } from "../../services/novels/novelServices";
 const AllNovel = () => {
     const {data: newNovels} = useQuery({
         queryFn: listNovelsAPI,
-        queryKey: ["list-new-novels", "sort_by", "created_at"]
+        queryKey: ["list-new-novels", "sort_by", "created_at", 4]
     });   
     
     return (
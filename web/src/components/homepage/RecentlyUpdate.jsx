@@ -9,7 +9,7 @@ const RecentlyUpdate = () => {
 
   const {data: updatedNovels} = useQuery({
     queryFn: listNovelsAPI,
-    queryKey: ["list-updated-novels", "sort_by", "updated_at"]
+    queryKey: ["list-updated-novels", "sort_by", "updated_at", 10]
   }); 
 
   useEffect(() => {
@@ -2,7 +2,7 @@ import { BookOpen, Search, User, Menu, X } from "lucide-react";
 import { Link, useNavigate } from "react-router-dom";
 import { useState } from "react";
 import { useQuery } from "@tanstack/react-query";
-import { listNovelsAPI } from "../../services/novels/novelServices";
+import { suggestAPI } from "../../services/search/searchServices";
 
 const Navbar = () => {
   const [isOpen, setIsOpen] = useState(false);
@@ -10,9 +10,10 @@ const Navbar = () => {
   const navigate = useNavigate();
 
   const { data: searchResult } = useQuery({
-    queryKey: ["list-novels", "search", searchTerm],
-    queryFn: listNovelsAPI,
-    enabled: searchTerm.trim().length > 0,
+    queryKey: ["suggest", searchTerm.trim().toLowerCase()],
+    queryFn: suggestAPI,
+    enabled: searchTerm.trim().length >= 2,
+    staleTime: 60 * 1000,
   });
 
   const handleSearchChange = (e) => {
@@ -27,8 +28,14 @@ const Navbar = () => {
     }
   };
 
-  const handleSuggestionClick = (novel) => {
-    navigate(`/novel/${novel.id}`);
+  const handleSuggestionClick = (suggestion) => {
+    if (suggestion.type === "novel") {
+      navigate(`/novel/${suggestion.novel_id}`);
+    } else if (suggestion.type === "genre") {
+      navigate(`/novel?genre=${suggestion.genre_id}`);
+    } else {
+      navigate(`/novel?q=${encodeURIComponent(suggestion.text)}`);
+    }
     setSearchTerm("");
     setIsOpen(false);
   };
@@ -64,13 +71,14 @@ const Navbar = () => {
             <Search className="text-white w-4 h-4 ml-2" />
             {searchResult?.data?.length > 0 && (
               <ul className="absolute top-full left-0 mt-1 bg-white w-full rounded-md shadow z-10 text-black">
-                {searchResult.data.map((novel) => (
+                {searchResult.data.map((suggestion) => (
                   <li
-                    key={novel.id}
-                    onClick={() => handleSuggestionClick(novel)}
+                    key={`${suggestion.type}-${suggestion.text}`}
+                    onClick={() => handleSuggestionClick(suggestion)}
                     className="px-3 py-2 hover:bg-gray-100 cursor-pointer text-sm"
                   >
-                    {novel.title}
+                    {suggestion.text}
+                    <span className="ml-2 text-xs text-gray-400">{suggestion.type}</span>
                   </li>
                 ))}
               </ul>
@@ -117,13 +125,14 @@ const Navbar = () => {
           <Search className="text-white w-4 h-4 ml-2" />
           {searchResult?.data?.length > 0 && (
             <ul className="absolute top-full mt-1 left-0 bg-white w-full rounded shadow z-10 text-black">
-              {searchResult.data.map((novel) => (
+              {searchResult.data.map((suggestion) => (
                 <li
-                  key={novel.id}
-                  onClick={() => handleSuggestionClick(novel)}
+                  key={`${suggestion.type}-${suggestion.text}`}
+                  onClick={() => handleSuggestionClick(suggestion)}
                   className="px-3 py-2 hover:bg-gray-100 cursor-pointer text-sm"
                 >
-                  {novel.title}
+                  {suggestion.text}
+                  <span className="ml-2 text-xs text-gray-400">{suggestion.type}</span>
                 </li>
               ))}
             </ul>
@@ -5,9 +5,12 @@ import App from './App.jsx'
 import {Provider} from 'react-redux'
 import {QueryClient, QueryClientProvider} from '@tanstack/react-query'
 import { store } from './redux/store/store.js'
+import { setupRefreshInterceptor } from './utils/refreshToken.js'
 
 const client = new QueryClient();
 
+setupRefreshInterceptor();
+
 createRoot(document.getElementById('root')).render(
   <StrictMode>
     <Provider store={store}>
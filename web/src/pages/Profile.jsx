@@ -20,6 +20,7 @@ const Profile = () => {
   const logoutHandler = () => {
     dispatch(logoutAction());
     localStorage.removeItem("userInfo");
+    localStorage.removeItem("refreshToken");
     navigate("/");
   };
 
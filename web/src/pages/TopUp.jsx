@@ -1,35 +1,23 @@
 import React, { useState } from "react";
 import PageWrapper from "../components/PageWrapper";
 import { Loader2 } from "lucide-react"; // Gunakan lucide-react atau ganti dengan spinner lainnya
-import { useMutation } from "@tanstack/react-query";
+import { useMutation, useQuery } from "@tanstack/react-query";
 import { createInvoiceAPI } from "../services/invoices/invoiceServices";
+import { listPlansAPI } from "../services/plans/planServices";
 
-const topupOptions = [
-  {
-    id: "lite",
-    name: "Topup Lite",
-    coins: 120,
-    price: "Rp 15.000",
-    description: "Cocok untuk coba-coba dan pengguna baru.",
-  },
-  {
-    id: "scroll",
-    name: "Topup Scroll",
-    coins: 700,
-    price: "Rp 65.000",
-    description: "Paket menengah untuk penggunaan rutin.",
-  },
-  {
-    id: "volume",
-    name: "Topup Volume",
-    coins: 1300,
-    price: "Rp 100.000",
-    description: "Paket besar, hemat dan praktis!",
-    highlight: true,
-  },
-];
+const formatPrice = (price, currency) =>
+  new Intl.NumberFormat("id-ID", {
+    style: "currency",
+    currency,
+    maximumFractionDigits: 0,
+  }).format(price);
 
 const TopUp = () => {
+  const { data: plans } = useQuery({
+    queryFn: listPlansAPI,
+    queryKey: ["list-plans"],
+  });
+
   const [selectedTopup, setSelectedTopup] = useState(null);
   const [showModal, setShowModal] = useState(false);
   const [isLoading, setIsLoading] = useState(false);
@@ -67,7 +55,7 @@ const TopUp = () => {
   const handleConfirmPayment = () => {
   if (!selectedTopup) return;
     setIsLoading(true);
-    createInvoice({ queryKey: ["invoice", selectedTopup.id] });
+    createInvoice({ queryKey: ["invoice", selectedTopup.code] });
   };
 
   return (
@@ -81,17 +69,17 @@ const TopUp = () => {
         </p>
 
         <div className="grid grid-cols-1 sm:grid-cols-2 md:grid-cols-3 gap-6">
-          {topupOptions.map((option) => (
+          {plans?.data.map((option) => (
             <div
               key={option.id}
-              className={`relative rounded-xl p-6 text-center transition-all duration-300 transform hover:-translate-y-2 shadow-lg hover:shadow-xl ${option.highlight
+              className={`relative rounded-xl p-6 text-center transition-all duration-300 transform hover:-translate-y-2 shadow-lg hover:shadow-xl ${option.bonus_coins > 0
                 ? "bg-gradient-to-br from-yellow-100 to-yellow-300 border-yellow-400 border-2"
                 : "bg-white border"
                 }`}
             >
-              {option.highlight && (
+              {option.bonus_coins > 0 && (
                 <div className="absolute top-0 right-0 bg-yellow-500 text-white text-xs px-2 py-1 rounded-bl-lg font-bold">
-                  ⭐ Favorit
+                  🎁 Bonus {option.bonus_coins}
                 </div>
               )}
               <h2 className="text-xl font-bold mb-2">{option.name}</h2>
@@ -100,7 +88,7 @@ const TopUp = () => {
                 🪙 {option.coins}
               </div>
               <p className="text-lg font-semibold text-green-600 mb-4">
-                {option.price}
+                {formatPrice(option.price, option.currency)}
               </p>
               <button
                 onClick={() => openModal(option)}
@@ -129,7 +117,8 @@ const TopUp = () => {
             <p className="text-center text-gray-600 mb-4">
               Paket: <strong>{selectedTopup.name}</strong>
               <br />
-              🪙 {selectedTopup.coins} | {selectedTopup.price}
+              🪙 {selectedTopup.coins + selectedTopup.bonus_coins} |{" "}
+              {formatPrice(selectedTopup.price, selectedTopup.currency)}
             </p>
 
             <button
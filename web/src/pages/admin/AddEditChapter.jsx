@@ -22,8 +22,19 @@ const validationSchema = Yup.object({
       then: (schema) => schema.required("Harga wajib diisi jika berbayar"),
       otherwise: (schema) => schema.notRequired(),
     }),
+  early_access_days: Yup.number()
+    .typeError("Lama akses awal harus berupa angka")
+    .integer("Lama akses awal harus bilangan bulat")
+    .min(0, "Lama akses awal tidak boleh negatif"),
+  publish_at: Yup.string(),
 });
 
+// datetime-local inputs take local time without a zone
+const toLocalInput = (iso) => {
+  const date = new Date(iso);
+  return new Date(date.getTime() - date.getTimezoneOffset() * 60000).toISOString().slice(0, 16);
+};
+
 const AddEditChapter = () => {
   const { novelID, slug } = useParams();
   const navigate = useNavigate();
@@ -35,6 +46,8 @@ const AddEditChapter = () => {
     enabled: isEdit,
   });
 
+  const isReleased = isEdit && !!detailChapter?.data?.published_at;
+
   const mutation = useMutation({
     mutationFn: isEdit ? editChapterAPI : addChapterAPI,
     mutationKey: [isEdit ? "edit-chapter" : "add-chapter"],
@@ -47,6 +60,8 @@ const AddEditChapter = () => {
       chapter_number: "",
       is_locked: false,
       price: 0,
+      early_access_days: "",
+      publish_at: "",
     },
     enableReinitialize: true,
     validationSchema,
@@ -58,6 +73,11 @@ const AddEditChapter = () => {
         chapter_number: parseFloat(values.chapter_number),
         is_locked: values.is_locked,
         price: values.is_locked ? values.price : 0,
+        early_access_days:
+          values.is_locked && values.early_access_days !== ""
+            ? parseInt(values.early_access_days, 10)
+            : undefined,
+        publish_at: values.publish_at && !isReleased ? new Date(values.publish_at).toISOString() : undefined,
       };
 
       if (isEdit) {
@@ -84,6 +104,10 @@ const AddEditChapter = () => {
         chapter_number: ch.chapter_number,
         is_locked: ch.is_locked,
         price: ch.price || 0,
+        early_access_days: ch.free_at
+          ? Math.round((new Date(ch.free_at) - new Date(ch.publish_at)) / 86400000)
+          : "",
+        publish_at: ch.published_at ? "" : toLocalInput(ch.publish_at),
       });
     }
   }, [detailChapter]);
@@ -175,6 +199,39 @@ const AddEditChapter = () => {
             )}
           </div>
         )}
+        {formik.values.is_locked && (
+          <div>
+            <label className="font-medium">Akses Awal (hari)</label>
+            <input
+              type="number"
+              min="0"
+              name="early_access_days"
+              {...formik.getFieldProps("early_access_days")}
+              className="w-full border rounded px-3 py-2"
+            />
+            <span className="text-sm text-gray-500">
+              Chapter gratis setelah sekian hari sejak terbit. Kosongkan agar tetap berbayar.
+            </span>
+            {formik.touched.early_access_days && formik.errors.early_access_days && (
+              <span className="text-sm text-red-500">{formik.errors.early_access_days}</span>
+            )}
+          </div>
+        )}
+
+        {!isReleased && (
+          <div>
+            <label className="font-medium">Jadwal Terbit</label>
+            <input
+              type="datetime-local"
+              name="publish_at"
+              {...formik.getFieldProps("publish_at")}
+              className="w-full border rounded px-3 py-2"
+            />
+            <span className="text-sm text-gray-500">
+              Kosongkan untuk langsung terbit.
+            </span>
+          </div>
+        )}
 
         <button
             type="submit"
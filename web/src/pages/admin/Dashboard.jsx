@@ -7,7 +7,7 @@ import { listNovelsAPI } from "../../services/novels/novelServices";
 const Dashboard = () => {
     const {data: novels} = useQuery({
         queryFn: listNovelsAPI,
-        queryKey: ["list-updated-novels", "sort_by", "updated_at"]
+        queryKey: ["list-updated-novels", "sort_by", "updated_at", 10]
     });
 
     return (
@@ -1,7 +1,7 @@
 import React, { useState } from "react";
 import { useParams, Link } from "react-router-dom";
 import { useQuery, useMutation, useQueryClient } from "@tanstack/react-query";
-import { detailNovelAPI } from "../../services/novels/novelServices";
+import { detailNovelAPI, setNovelStatusAPI } from "../../services/novels/novelServices";
 import { deleteChapterAPI } from "../../services/chapters/chapterServices";
 import { Pencil } from "lucide-react";
 
@@ -18,6 +18,27 @@ const NovelDetail = () => {
 
   const [selectedChapter, setSelectedChapter] = useState(null);
   const [showModal, setShowModal] = useState(false);
+  const [status, setStatus] = useState("");
+  const [publishAt, setPublishAt] = useState("");
+
+  const { mutate: changeStatus, isLoading: isChangingStatus, error: statusError } = useMutation({
+    mutationFn: setNovelStatusAPI,
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ["detail-novel", novelID] });
+      queryClient.invalidateQueries({ queryKey: ["list-admin-novels"] });
+      setStatus("");
+      setPublishAt("");
+    },
+  });
+
+  const handleStatusSubmit = (e) => {
+    e.preventDefault();
+    changeStatus({
+      novelID,
+      status,
+      published_at: publishAt ? new Date(publishAt).toISOString() : undefined,
+    });
+  };
 
   const { mutate: deleteChapter, isLoading: isDeleting } = useMutation({
     mutationFn: ({ novelID, slug }) =>
@@ -75,6 +96,44 @@ const NovelDetail = () => {
             <span className="font-semibold block mb-1">Sinopsis:</span>
             <p className="text-justify leading-relaxed">{novelData.synopsis}</p>
           </div>
+          <form onSubmit={handleStatusSubmit} className="mt-4 flex flex-wrap items-center gap-2">
+            <span className="text-sm text-gray-600">
+              Status: <span className="font-semibold">{novelData.status}</span>
+              {novelData.published_at && ` (${new Date(novelData.published_at).toLocaleString()})`}
+            </span>
+            <select
+              value={status}
+              onChange={(e) => setStatus(e.target.value)}
+              className="border rounded px-2 py-1 text-sm"
+            >
+              <option value="">Ubah status…</option>
+              <option value="draft">draft</option>
+              <option value="scheduled">scheduled</option>
+              <option value="published">published</option>
+              <option value="hiatus">hiatus</option>
+              <option value="completed">completed</option>
+            </select>
+            {status === "scheduled" && (
+              <input
+                type="datetime-local"
+                value={publishAt}
+                onChange={(e) => setPublishAt(e.target.value)}
+                className="border rounded px-2 py-1 text-sm"
+              />
+            )}
+            <button
+              type="submit"
+              disabled={!status || isChangingStatus}
+              className="bg-black text-white px-3 py-1 rounded text-sm disabled:opacity-50"
+            >
+              Simpan
+            </button>
+            {statusError && (
+              <p className="w-full text-sm text-red-600">
+                {statusError.response?.data?.error || statusError.message}
+              </p>
+            )}
+          </form>
           <div className="mt-4">
             <Link
               to={`/admin/edit-novel/${novelData.id}`}
@@ -115,6 +174,11 @@ const NovelDetail = () => {
                     <p className="text-sm text-gray-500">
                       Diperbarui: {new Date(chapter.updated_at).toLocaleString()}
                     </p>
+                    {!chapter.published_at && (
+                      <p className="text-sm text-amber-600">
+                        Terjadwal: {new Date(chapter.publish_at).toLocaleString()}
+                      </p>
+                    )}
                   </div>
                   <div className="flex gap-2">
                     <Link
@@ -2,13 +2,13 @@ import React, { useState } from "react";
 import { Link } from "react-router-dom";
 import { Pencil, Trash2, BookOpen } from "lucide-react";
 import { useQuery, useMutation, useQueryClient } from "@tanstack/react-query";
-import { listNovelsAPI, deleteNovelAPI } from "../../services/novels/novelServices";
+import { listAdminNovelsAPI, deleteNovelAPI } from "../../services/novels/novelServices";
 
 const NovelList = () => {
   const queryClient = useQueryClient();
   const { data: novels } = useQuery({
-    queryKey: ["list-novels"],
-    queryFn: listNovelsAPI,
+    queryKey: ["list-admin-novels"],
+    queryFn: listAdminNovelsAPI,
   });
 
   const [selectedId, setSelectedId] = useState(null);
@@ -17,7 +17,7 @@ const NovelList = () => {
   const { mutate: deleteNovel, isLoading } = useMutation({
     mutationFn: ({ novelID }) => deleteNovelAPI({ queryKey: ["delete-novel", novelID] }),
     onSuccess: () => {
-      queryClient.invalidateQueries({ queryKey: ["list-novels"] });
+      queryClient.invalidateQueries({ queryKey: ["list-admin-novels"] });
       setShowModal(false);
     },
   });
@@ -61,6 +61,7 @@ const NovelList = () => {
               />
               <h2 className="font-semibold text-lg">{novel?.title}</h2>
               <p className="text-sm text-gray-600 mb-1">By: {novel?.author}</p>
+              <p className="text-xs uppercase tracking-wide text-gray-500 mb-2">{novel?.status}</p>
               <div className="mt-auto flex gap-2 flex-wrap">
                 <Link
                   to={`/admin/novels/${novel?.id}`}
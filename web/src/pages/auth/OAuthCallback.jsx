@@ -0,0 +1,54 @@
+import React, { useEffect, useState } from "react";
+import { useNavigate } from "react-router-dom";
+import PageWrapper from "../../components/PageWrapper";
+import AlertMessage from "../../components/alert/AlertMessage";
+
+const errorMessages = {
+  invalid_state: "Your sign in session expired. Please try again.",
+  email_not_verified: "Your provider did not confirm your email. Sign in with your password instead.",
+  activation_required: "We sent you an email. Please activate your account first.",
+  account_inactive: "Please activate your account first.",
+  already_linked: "This account is already linked to another login from that provider.",
+  access_denied: "Sign in was cancelled.",
+};
+
+const OAuthCallback = () => {
+  const navigate = useNavigate();
+  const [errorMsg, setErrorMsg] = useState("");
+
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    window.history.replaceState(null, "", window.location.pathname);
+
+    if (params.get("token")) {
+      localStorage.setItem("userInfo", JSON.stringify(params.get("token")));
+      localStorage.setItem("refreshToken", JSON.stringify(params.get("refresh_token")));
+      navigate("/profile");
+      window.location.reload();
+      return;
+    }
+
+    if (params.get("challenge_token")) {
+      setErrorMsg("Two-factor authentication is enabled. Please sign in with your password and code.");
+      return;
+    }
+
+    setErrorMsg(errorMessages[params.get("error")] || "Sign in failed. Please try again.");
+  }, []);
+
+  return (
+    <PageWrapper>
+      <div className="flex flex-col items-center justify-center min-h-screen px-4">
+        <div className="w-full max-w-sm flex flex-col gap-4">
+          {errorMsg ? (
+            <AlertMessage type="error" message={errorMsg} />
+          ) : (
+            <AlertMessage type="loading" message="Signing you in..." />
+          )}
+        </div>
+      </div>
+    </PageWrapper>
+  );
+};
+
+export default OAuthCallback;
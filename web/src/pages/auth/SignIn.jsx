@@ -9,6 +9,7 @@ import { useDispatch } from "react-redux";
 import { signInAPI } from "../../services/users/userServices";
 import AlertMessage from "../../components/alert/AlertMessage";
 import { getUser } from "../../utils/getUser";
+import { BASE_URL } from "../../utils/url";
 
 //! validations
 const validationSchema = Yup.object({
@@ -36,7 +37,8 @@ const SignIn = () => {
       mutateAsync(values)
         .then(data=>{
           dispatch(loginAction(data));
-          localStorage.setItem("userInfo", JSON.stringify(data.data));
+          localStorage.setItem("userInfo", JSON.stringify(data.data.token));
+          localStorage.setItem("refreshToken", JSON.stringify(data.data.refresh_token));
         })
         .catch(e => console.log(e));
     }
@@ -108,6 +110,14 @@ const SignIn = () => {
             Sign In
           </button>
 
+          {/* Social Login */}
+          <a
+            href={`${BASE_URL}/authentication/oidc/google`}
+            className="border border-black text-center font-semibold py-2 rounded-full shadow hover:bg-gray-100 transition-all"
+          >
+            Sign in with Google
+          </a>
+
           {/* Sign Up Link */}
           <p className="text-center text-sm mt-2">
             Don’t have an account yet?{" "}
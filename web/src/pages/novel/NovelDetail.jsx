@@ -104,6 +104,13 @@ const NovelDetail = () => {
 
           <div className="flex-1">
             <h1 className="text-3xl font-bold mb-2">{novel?.data.title}</h1>
+            {(novel?.data.status === "completed" || novel?.data.status === "hiatus") && (
+              <span className={`inline-block mb-3 px-2 py-0.5 rounded-full text-xs font-semibold ${
+                novel?.data.status === "completed" ? "bg-green-100 text-green-800" : "bg-yellow-100 text-yellow-800"
+              }`}>
+                {novel?.data.status === "completed" ? "Completed" : "On hiatus"}
+              </span>
+            )}
             <p className="text-base text-gray-700 leading-relaxed mb-3">{novel?.data.synopsis}</p>
             <p className="text-sm text-gray-800 mb-1">
               <span className="font-semibold">Author:</span> {novel?.data.author}
@@ -156,6 +163,11 @@ const NovelDetail = () => {
                       <div>
                         Chapter {chapter?.chapter_number} – {chapter?.title}
                         <span className="ml-2 text-sm text-red-500 italic">(Berbayar)</span>
+                        {chapter?.free_at && (
+                          <span className="ml-2 text-xs text-gray-500">
+                            Gratis pada {new Date(chapter.free_at).toLocaleString()}
+                          </span>
+                        )}
                       </div>
                       <div className="flex items-center gap-2">
                         <span className="text-sm text-gray-600">{chapter?.price?.toLocaleString()} coin</span>
@@ -35,6 +35,7 @@ const ChangePassword = () => {
         .then(data => {
           dispatch(logoutAction());
           localStorage.removeItem("userInfo");
+          localStorage.removeItem("refreshToken");
           navigate("/login");
         })
         .catch(e => console.log(e));
@@ -2,12 +2,27 @@ import React from "react";
 import PageWrapper from "../../components/PageWrapper";
 import { useQuery } from "@tanstack/react-query";
 import { listTransactionsAPI } from "../../services/invoices/invoiceServices";
+import { coinHistoryAPI } from "../../services/users/userServices";
+
+const coinKinds = {
+  opening_balance: "Saldo Awal",
+  top_up: "Top Up",
+  chapter_purchase: "Beli Chapter",
+  refund: "Refund",
+  admin_grant: "Dari Admin",
+  bonus: "Bonus",
+};
 
 const TransactionHistory = () => {
   const {data: transactions} = useQuery({
     queryKey: ["list-transactions"],
     queryFn: listTransactionsAPI,
   });
+
+  const {data: coins} = useQuery({
+    queryKey: ["coin-history"],
+    queryFn: coinHistoryAPI,
+  });
   
   return (
     <PageWrapper>
@@ -65,6 +80,39 @@ const TransactionHistory = () => {
             </tbody>
           </table>
         </div>
+
+        <h2 className="text-2xl font-bold text-center mt-16 mb-6">
+          Coin History
+        </h2>
+        <div className="overflow-x-auto mb-16">
+          <table className="min-w-full bg-white rounded-lg shadow border border-gray-200">
+            <thead>
+              <tr className="bg-gray-100 text-left text-sm text-gray-700">
+                <th className="py-3 px-4">Date</th>
+                <th className="py-3 px-4">Type</th>
+                <th className="py-3 px-4">Reference</th>
+                <th className="py-3 px-4">Coin</th>
+                <th className="py-3 px-4">Balance</th>
+              </tr>
+            </thead>
+            <tbody>
+              {coins?.data?.map((tx) => (
+                <tr
+                  key={tx.id}
+                  className="hover:bg-gray-50 border-t border-gray-200 transition"
+                >
+                  <td className="py-3 px-4">{new Date(tx.created_at).toLocaleString()}</td>
+                  <td className="py-3 px-4">{coinKinds[tx.kind] || tx.kind}</td>
+                  <td className="py-3 px-4">{tx.reference}</td>
+                  <td className={`py-3 px-4 font-medium ${tx.amount > 0 ? "text-green-600" : "text-red-500"}`}>
+                    {tx.amount > 0 ? `+${tx.amount}` : tx.amount}
+                  </td>
+                  <td className="py-3 px-4">{tx.balance}</td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        </div>
       </div>
     </PageWrapper>
   );
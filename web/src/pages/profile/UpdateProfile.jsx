@@ -34,6 +34,7 @@ const UpdateProfile = () => {
           if (values.email){
             dispatch(logoutAction());
             localStorage.removeItem("userInfo");
+            localStorage.removeItem("refreshToken");
             navigate("/login");
           }
         })
@@ -30,6 +30,8 @@ import Dashboard from "../pages/admin/Dashboard";
 import GenreManager from "../pages/admin/GenreManager";
 import AuthRoute from "../components/auth/AuthRoute";
 import ConfirmationPage from "../pages/auth/ConfirmationPage";
+import ConfirmEmailPage from "../pages/auth/ConfirmEmailPage";
+import OAuthCallback from "../pages/auth/OAuthCallback";
 import ResetPassword from "../pages/auth/ResetPassword";
 import AdminRoute from "../components/auth/AdminRoute";
 import AddNovel from "../pages/admin/AddNovel";
@@ -126,6 +128,8 @@ const AppRouter = () => {
         <Route path="/register" element={<SignUp />} />
         <Route path="/forgot-password" element={<ForgotPassword />} />
         <Route path="/confirm/:token" element={<ConfirmationPage/>}/>
+        <Route path="/confirm-email/:token" element={<ConfirmEmailPage/>}/>
+        <Route path="/oauth/callback" element={<OAuthCallback/>}/>
         <Route path="/reset/:token" element={<ResetPassword/>}/>
 
         {/* Admin Routes */}
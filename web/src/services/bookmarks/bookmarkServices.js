@@ -1,6 +1,7 @@
 import axios from 'axios';
 import { BASE_URL } from '../../utils/url';
 import { getUser } from '../../utils/getUser';
+import { toPage } from '../novels/novelServices';
 
 const token = getUser();
 
@@ -19,7 +20,7 @@ export const listBookmarksAPI = async() => {
             Authorization: `Bearer ${token}`
         }
     });
-    return response.data;
+    return toPage(response);
 }
 
 export const deleteBookmarkAPI = async({bookmarkID}) => {
@@ -24,13 +24,15 @@ export const unlockChapterAPI = async({queryKey}) => {
     return response.data;
 }
 
-export const addChapterAPI = async({novelID, title, content, chapter_number, is_locked, price}) => {
+export const addChapterAPI = async({novelID, title, content, chapter_number, is_locked, price, early_access_days, publish_at}) => {
     const response = await axios.post(`${BASE_URL}/novels/${novelID}/chapters`, {
         title,
         content,
         chapter_number,
         is_locked,
-        price
+        price,
+        early_access_days,
+        publish_at
     }, {
         headers : {
             Authorization: `Bearer ${token}`
@@ -40,13 +42,15 @@ export const addChapterAPI = async({novelID, title, content, chapter_number, is_
 }
 
 
-export const editChapterAPI = async({novelID, slug, title, content, chapter_number, is_locked, price}) => {
+export const editChapterAPI = async({novelID, slug, title, content, chapter_number, is_locked, price, early_access_days, publish_at}) => {
     const response = await axios.patch(`${BASE_URL}/novels/${novelID}/chapters/${slug}`, {
         title,
         content,
         chapter_number,
         is_locked,
-        price
+        price,
+        early_access_days,
+        publish_at
     }, {
         headers : {
             Authorization: `Bearer ${token}`
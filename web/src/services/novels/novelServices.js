@@ -4,23 +4,42 @@ import { getUser } from '../../utils/getUser';
 
 const token = getUser();
 
+// Listings come back as a page; data holds its items so callers can map over it.
+export const toPage = (response) => {
+    const page = response.data.data;
+    return { data: page.items, nextCursor: page.next_cursor, total: page.total };
+}
+
 export const listNovelsAPI = async({queryKey}) => {
-    let URL = `${BASE_URL}/novels`;
-    const [_key, params, value] = queryKey;
+    const [_key, params, value, limit] = queryKey;
+    const query = {};
     if (params == "sort_by") {
-        URL += `?sort_by=${value}`
+        query.sort_by = value;
     }else if (params == "search") {
-        URL += `?search=${value}`
+        query.search = value;
+    }
+    if (limit) {
+        query.limit = limit;
     }
 
-    const response = await axios.get(`${URL}`);
-    return response.data;
+    const response = await axios.get(`${BASE_URL}/novels`, { params: query });
+    return toPage(response);
+}
+
+export const listAdminNovelsAPI = async() => {
+    const response = await axios.get(`${BASE_URL}/admin/novels`, {
+        params: { limit: 100 },
+        headers: {
+            Authorization: `Bearer ${token}`
+        }
+    });
+    return toPage(response);
 }
 
 export const listNovelsFromGenreAPI = async({queryKey}) => {
     const [_key, genreID] = queryKey;
     const response = await axios.get(`${BASE_URL}/genres/${genreID}/novels`);
-    return response.data;
+    return toPage(response);
 }
 
 export const detailNovelAPI = async({queryKey}) => {
@@ -58,6 +77,18 @@ export const editNovelAPI = async ({ novelID, title, author, synopsis, genre_ids
   return response.data;
 };
 
+export const setNovelStatusAPI = async ({ novelID, status, published_at }) => {
+  const response = await axios.patch(`${BASE_URL}/novels/${novelID}/status`, {
+    status,
+    published_at,
+  }, {
+    headers: {
+      Authorization: `Bearer ${token}`
+    }
+  });
+  return response.data;
+};
+
 export const deleteNovelAPI = async({queryKey}) => {
     const [_key, novelID] = queryKey;
     const response = await axios.delete(`${BASE_URL}/novels/${novelID}`, {
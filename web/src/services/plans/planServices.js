@@ -0,0 +1,7 @@
+import axios from 'axios';
+import { BASE_URL } from '../../utils/url';
+
+export const listPlansAPI = async() => {
+    const response = await axios.get(`${BASE_URL}/plans`);
+    return response.data;
+}
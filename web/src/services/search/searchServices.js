@@ -0,0 +1,8 @@
+import axios from 'axios';
+import { BASE_URL } from '../../utils/url';
+
+export const suggestAPI = async({queryKey}) => {
+    const [_key, q] = queryKey;
+    const response = await axios.get(`${BASE_URL}/search/suggest`, { params: { q } });
+    return response.data;
+}
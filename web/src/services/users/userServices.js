@@ -1,6 +1,7 @@
 import axios from 'axios';
 import { BASE_URL } from '../../utils/url';
 import { getUser } from '../../utils/getUser';
+import { toPage } from '../novels/novelServices';
 
 const token = getUser();
 
@@ -84,3 +85,15 @@ export const checkAdminAPI = async() => {
 
     return response.data;
 }
+
+
+export const coinHistoryAPI = async() => {
+    const response = await axios.get(`${BASE_URL}/users/coins/history`, {
+        params: { limit: 100 },
+        headers: {
+            Authorization: `Bearer ${token}`
+        }
+    });
+
+    return toPage(response);
+}
\ No newline at end of file
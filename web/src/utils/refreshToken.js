@@ -0,0 +1,45 @@
+import axios from 'axios';
+import { BASE_URL } from './url';
+
+let refreshing = null;
+
+export const setupRefreshInterceptor = () => {
+    axios.interceptors.response.use(
+        (response) => response,
+        async (error) => {
+            const original = error.config;
+            const refreshToken = JSON.parse(localStorage.getItem("refreshToken")) || null;
+
+            if (
+                error.response?.status !== 401 ||
+                !refreshToken ||
+                original._retry ||
+                original.url?.startsWith(`${BASE_URL}/authentication`)
+            ) {
+                return Promise.reject(error);
+            }
+
+            original._retry = true;
+
+            try {
+                refreshing = refreshing || axios.post(`${BASE_URL}/authentication/refresh`, {
+                    refresh_token: refreshToken
+                });
+                const response = await refreshing;
+
+                localStorage.setItem("userInfo", JSON.stringify(response.data.data.token));
+                localStorage.setItem("refreshToken", JSON.stringify(response.data.data.refresh_token));
+
+                // services read the token once at import time, so reload to pick it up
+                window.location.reload();
+                return new Promise(() => {});
+            } catch (e) {
+                localStorage.removeItem("userInfo");
+                localStorage.removeItem("refreshToken");
+                return Promise.reject(error);
+            } finally {
+                refreshing = null;
+            }
+        }
+    );
+};